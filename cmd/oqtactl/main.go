@@ -30,7 +30,7 @@ import (
 //
 func synopsis() {
 	fmt.Print(`
-synopsis: oqtactl {serve|load|unload|save|ls|dump|map|search|resync|config|version} ...
+synopsis: oqtactl {serve|load|unload|copy|move|swap|mount|save|format|ls|dump|get|put|delete|rename|list-basic|fsck|defrag|diff|map|search|resync|config|version} ...
 
 run 'oqtactl {action} -h|--help' to see detailed info
 
@@ -63,15 +63,54 @@ func main() {
 	case "unload":
 		run.DieOnError(run.NewUnload().Execute(args))
 
+	case "copy":
+		run.DieOnError(run.NewCopy().Execute(args))
+
+	case "move":
+		run.DieOnError(run.NewMove().Execute(args))
+
+	case "swap":
+		run.DieOnError(run.NewSwap().Execute(args))
+
+	case "mount":
+		run.DieOnError(run.NewMount().Execute(args))
+
 	case "save":
 		run.DieOnError(run.NewSave().Execute(args))
 
+	case "format":
+		run.DieOnError(run.NewFormat().Execute(args))
+
 	case "ls":
 		run.DieOnError(run.NewList().Execute(args))
 
 	case "dump":
 		run.DieOnError(run.NewDump().Execute(args))
 
+	case "get":
+		run.DieOnError(run.NewGet().Execute(args))
+
+	case "put":
+		run.DieOnError(run.NewPut().Execute(args))
+
+	case "delete":
+		run.DieOnError(run.NewDelete().Execute(args))
+
+	case "rename":
+		run.DieOnError(run.NewRename().Execute(args))
+
+	case "list-basic":
+		run.DieOnError(run.NewListBasic().Execute(args))
+
+	case "fsck":
+		run.DieOnError(run.NewFsck().Execute(args))
+
+	case "defrag":
+		run.DieOnError(run.NewDefrag().Execute(args))
+
+	case "diff":
+		run.DieOnError(run.NewDiff().Execute(args))
+
 	case "map":
 		run.DieOnError(run.NewMap().Execute(args))
 
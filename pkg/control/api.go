@@ -37,6 +37,9 @@ import (
 	"github.com/xelalexv/oqtadrive/pkg/repo"
 )
 
+// maximum size of cartridge and file uploads
+const maxUploadSize = 1048576
+
 //
 type APIServer interface {
 	Serve() error
@@ -44,8 +47,8 @@ type APIServer interface {
 }
 
 //
-func NewAPIServer(addr, repo string, d *daemon.Daemon) APIServer {
-	return &api{address: addr, repository: repo, daemon: d}
+func NewAPIServer(addr, repo string, webDAV bool, d *daemon.Daemon) APIServer {
+	return &api{address: addr, repository: repo, webDAV: webDAV, daemon: d}
 }
 
 //
@@ -53,6 +56,7 @@ type api struct {
 	//
 	address    string
 	repository string
+	webDAV     bool
 	//
 	daemon *daemon.Daemon
 	server *http.Server
@@ -74,17 +78,47 @@ func (a *api) Serve() error {
 	addRoute(router, "load", "PUT", "/drive/{drive:[1-8]}", a.load)
 	addRoute(router, "unload", "GET", "/drive/{drive:[1-8]}/unload", a.unload)
 	addRoute(router, "save", "GET", "/drive/{drive:[1-8]}", a.save)
+	addRoute(router, "format", "PUT", "/drive/{drive:[1-8]}/format", a.format)
 	addRoute(router, "dump", "GET", "/drive/{drive:[1-8]}/dump", a.dump)
 	addRoute(router, "map", "GET", "/map", a.getDriveMap)
 	addRoute(router, "map", "PUT", "/map", a.setDriveMap)
 	addRoute(router, "drivels", "GET", "/drive/{drive:[1-8]}/list", a.driveList)
+	addRoute(router, "check", "GET", "/drive/{drive:[1-8]}/check", a.check)
+	addRoute(router, "defrag", "PUT", "/drive/{drive:[1-8]}/defrag", a.defrag)
+	addRoute(router, "diff", "GET", "/drive/{drive:[1-8]}/diff", a.diff)
+	addRoute(router, "diff", "PUT", "/drive/{drive:[1-8]}/diff", a.diffUpload)
+	addRoute(router, "copy", "POST", "/drive/{drive:[1-8]}/copy", a.copy)
+	addRoute(router, "move", "POST", "/drive/{drive:[1-8]}/move", a.move)
+	addRoute(router, "swap", "POST", "/drive/{drive:[1-8]}/swap", a.swap)
+	addRoute(router, "mapdir", "PUT", "/drive/{drive:[1-8]}/dir", a.mapDir)
+	addRoute(router, "unmapdir", "DELETE", "/drive/{drive:[1-8]}/dir",
+		a.unmapDir)
+	addRoute(router, "get", "GET", "/drive/{drive:[1-8]}/file/{name}",
+		a.getFile)
+	addRoute(router, "put", "PUT", "/drive/{drive:[1-8]}/file/{name}",
+		a.putFile)
+	addRoute(router, "delete", "DELETE", "/drive/{drive:[1-8]}/file/{name}",
+		a.deleteFile)
+	addRoute(router, "listing", "GET",
+		"/drive/{drive:[1-8]}/file/{name}/listing", a.getListing)
+	addRoute(router, "screen", "GET",
+		"/drive/{drive:[1-8]}/file/{name}/screen.png", a.getScreen)
+	addRoute(router, "rename", "PUT", "/drive/{drive:[1-8]}/file/{name}/rename",
+		a.renameFile)
 	addRoute(router, "resync", "PUT", "/resync", a.resync)
 	addRoute(router, "config", "GET", "/config", a.getConfig)
 	addRoute(router, "config", "PUT", "/config", a.setConfig)
 	addRoute(router, "search", "GET", "/search", a.search)
+	addRoute(router, "thumbnail", "GET", "/repo/thumbnail", a.getThumbnail)
 	addRoute(router, "upgrade", "POST", "/upgrade", a.upgrade)
 	addRoute(router, "version", "GET", "/version", a.version)
 
+	if a.webDAV {
+		log.Infof("WebDAV share enabled at %s/", davPrefix)
+		router.PathPrefix(davPrefix).Handler(
+			requestLogger(a.webDAVHandler(), "webdav"))
+	}
+
 	router.PathPrefix("/").Handler(
 		requestLogger(http.FileServer(http.Dir("./ui/web/")), "webui"))
 
@@ -145,6 +179,15 @@ func (a *api) Stop() error {
 	return nil
 }
 
+// notifyChange makes the watcher send a change notification in its next round.
+// It does not block, since a pending notification covers any further changes.
+func (a *api) notifyChange() {
+	select {
+	case a.forceNotify <- true:
+	default:
+	}
+}
+
 //
 func (a *api) getCartridges() []*Cartridge {
 
@@ -291,6 +334,27 @@ func sendStreamReply(r io.Reader, statusCode int, w http.ResponseWriter) {
 	}
 }
 
+//
+func sendBinaryReply(body []byte, name string, statusCode int,
+	w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition",
+		fmt.Sprintf("attachment; filename=%q", name))
+	w.WriteHeader(statusCode)
+	if _, err := w.Write(body); err != nil {
+		log.Errorf("problem sending reply: %v", err)
+	}
+}
+
+//
+func sendPNGReply(img []byte, w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "image/png")
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(img); err != nil {
+		log.Errorf("problem sending reply: %v", err)
+	}
+}
+
 //
 func sendJSONReply(obj interface{}, statusCode int, w http.ResponseWriter) {
 	setHeaders(w.Header(), true)
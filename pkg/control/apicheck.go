@@ -0,0 +1,78 @@
+/*
+   OqtaDrive - Sinclair Microdrive emulator
+   Copyright (c) 2022, Alexander Vollschwitz
+
+   This file is part of OqtaDrive.
+
+   OqtaDrive is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   OqtaDrive is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with OqtaDrive. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package control
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/xelalexv/oqtadrive/pkg/daemon"
+	"github.com/xelalexv/oqtadrive/pkg/microdrive/base"
+)
+
+//
+func (a *api) check(w http.ResponseWriter, req *http.Request) {
+
+	var report *base.CheckReport
+
+	if isFlagSet(req, "repair") {
+
+		drive := getDrive(w, req)
+		if drive == -1 {
+			return
+		}
+
+		if handleUpdateError(a.daemon.UpdateCartridge(drive,
+			func(c *base.Cartridge) error {
+				var err error
+				if report, err = c.FS().Check(true); err == nil &&
+					report.Repaired() == 0 {
+					return daemon.ErrUnchanged
+				}
+				return err
+			}), drive, w) {
+			return
+		}
+		if report.Repaired() > 0 {
+			a.notifyChange()
+		}
+
+	} else {
+		a.withCartridge(w, req, func(cart *base.Cartridge) {
+			var err error
+			if !cart.IsFormatted() {
+				err = fmt.Errorf("cartridge is not formatted")
+			} else {
+				report, err = cart.FS().Check(false)
+			}
+			handleError(err, http.StatusUnprocessableEntity, w)
+		})
+		if report == nil {
+			return
+		}
+	}
+
+	if wantsJSON(req) {
+		sendJSONReply(report, http.StatusOK, w)
+	} else {
+		sendReply([]byte(report.String()), http.StatusOK, w)
+	}
+}
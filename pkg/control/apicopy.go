@@ -0,0 +1,89 @@
+/*
+   OqtaDrive - Sinclair Microdrive emulator
+   Copyright (c) 2022, Alexander Vollschwitz
+
+   This file is part of OqtaDrive.
+
+   OqtaDrive is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   OqtaDrive is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with OqtaDrive. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package control
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/xelalexv/oqtadrive/pkg/daemon"
+)
+
+//
+func (a *api) copy(w http.ResponseWriter, req *http.Request) {
+	a.transfer(w, req, "copied cartridge in drive %d to drive %d", func(from, to int) error {
+		return a.daemon.CopyCartridge(from, to, isFlagSet(req, "force"))
+	})
+}
+
+//
+func (a *api) move(w http.ResponseWriter, req *http.Request) {
+	a.transfer(w, req, "moved cartridge in drive %d to drive %d", func(from, to int) error {
+		return a.daemon.MoveCartridge(from, to, isFlagSet(req, "force"))
+	})
+}
+
+//
+func (a *api) swap(w http.ResponseWriter, req *http.Request) {
+	a.transfer(w, req, "swapped cartridges in drives %d and %d", func(from, to int) error {
+		return a.daemon.SwapCartridges(from, to)
+	})
+}
+
+// transfer runs op for the drive addressed by the request and the drive given
+// by argument "to", and sends a reply with the outcome, formatted with done
+func (a *api) transfer(w http.ResponseWriter, req *http.Request, done string,
+	op func(from, to int) error) {
+
+	from := getDrive(w, req)
+	if from == -1 {
+		return
+	}
+
+	to, err := strconv.Atoi(getArg(req, "to"))
+	if err != nil {
+		handleError(fmt.Errorf("invalid target drive: '%s'", getArg(req, "to")),
+			http.StatusUnprocessableEntity, w)
+		return
+	}
+
+	if err := op(from, to); err != nil {
+		switch {
+		case errors.Is(err, daemon.ErrLocked):
+			handleError(err, http.StatusLocked, w)
+		case errors.Is(err, daemon.ErrModified):
+			handleError(err, http.StatusConflict, w)
+		case errors.Is(err, daemon.ErrHardwareDrive),
+			errors.Is(err, daemon.ErrInvalidDrive),
+			errors.Is(err, daemon.ErrNoCartridge),
+			errors.Is(err, daemon.ErrSameDrive):
+			handleError(err, http.StatusUnprocessableEntity, w)
+		default:
+			handleError(err, http.StatusInternalServerError, w)
+		}
+		return
+	}
+
+	sendReply([]byte(fmt.Sprintf(done, from, to)), http.StatusOK, w)
+	a.notifyChange()
+}
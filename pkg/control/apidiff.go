@@ -0,0 +1,105 @@
+/*
+   OqtaDrive - Sinclair Microdrive emulator
+   Copyright (c) 2022, Alexander Vollschwitz
+
+   This file is part of OqtaDrive.
+
+   OqtaDrive is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   OqtaDrive is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with OqtaDrive. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package control
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/xelalexv/oqtadrive/pkg/microdrive/base"
+	"github.com/xelalexv/oqtadrive/pkg/microdrive/format"
+)
+
+// diff compares the cartridge in the addressed drive with the cartridge in the
+// drive given by argument "with"
+func (a *api) diff(w http.ResponseWriter, req *http.Request) {
+
+	other, err := strconv.Atoi(getArg(req, "with"))
+	if err == nil && (other < 1 || other > 8) {
+		err = fmt.Errorf("invalid drive number: %d", other)
+	}
+	if handleError(err, http.StatusUnprocessableEntity, w) {
+		return
+	}
+
+	a.withCartridge(w, req, func(cart *base.Cartridge) {
+		right := cart
+		if getDrive(w, req) != other {
+			if right = a.lockCartridge(other, w); right == nil {
+				return
+			}
+			defer right.Unlock()
+		}
+		sendDiffReport(cart, right, w, req)
+	})
+}
+
+// diffUpload compares the cartridge in the addressed drive with the cartridge
+// file sent in the request body. With flag "reverse" set, the uploaded
+// cartridge is the left side of the comparison.
+func (a *api) diffUpload(w http.ResponseWriter, req *http.Request) {
+
+	in := http.MaxBytesReader(nil, req.Body, maxUploadSize)
+
+	cr, err := format.NewSniffingCartReader(in, getArg(req, "compressor"),
+		getArg(req, "type"), "")
+	if handleError(err, http.StatusUnprocessableEntity, w) {
+		return
+	}
+	defer cr.Close()
+
+	reader, err := format.NewFormat(cr.Type())
+	if handleError(err, http.StatusUnprocessableEntity, w) {
+		return
+	}
+
+	uploaded, err := reader.Read(cr, true, false, nil)
+	if err != nil {
+		handleError(fmt.Errorf("cartridge corrupted: %v", err),
+			http.StatusUnprocessableEntity, w)
+		return
+	}
+
+	a.withCartridge(w, req, func(cart *base.Cartridge) {
+		if isFlagSet(req, "reverse") {
+			sendDiffReport(uploaded, cart, w, req)
+		} else {
+			sendDiffReport(cart, uploaded, w, req)
+		}
+	})
+}
+
+//
+func sendDiffReport(left, right *base.Cartridge, w http.ResponseWriter,
+	req *http.Request) {
+
+	report, err := base.Diff(left, right, isFlagSet(req, "sectors"))
+	if handleError(err, http.StatusUnprocessableEntity, w) {
+		return
+	}
+
+	if wantsJSON(req) {
+		sendJSONReply(report, http.StatusOK, w)
+	} else {
+		sendReply([]byte(report.String()), http.StatusOK, w)
+	}
+}
@@ -0,0 +1,122 @@
+/*
+   OqtaDrive - Sinclair Microdrive emulator
+   Copyright (c) 2022, Alexander Vollschwitz
+
+   This file is part of OqtaDrive.
+
+   OqtaDrive is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   OqtaDrive is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with OqtaDrive. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package control
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/xelalexv/oqtadrive/pkg/daemon"
+	"github.com/xelalexv/oqtadrive/pkg/microdrive/client"
+)
+
+// mapDir maps a directory inside the cartridge repository to a drive
+func (a *api) mapDir(w http.ResponseWriter, req *http.Request) {
+
+	drive := getDrive(w, req)
+	if drive == -1 {
+		return
+	}
+
+	if a.repository == "" {
+		handleError(fmt.Errorf("cartridge repository is not enabled"),
+			http.StatusNotAcceptable, w)
+		return
+	}
+
+	dir, err := a.repoPath(getArg(req, "path"))
+	if handleError(err, http.StatusUnprocessableEntity, w) {
+		return
+	}
+
+	cl := client.UNKNOWN
+	if c := getArg(req, "client"); c != "" {
+		if cl = client.GetClient(c); cl == client.UNKNOWN {
+			handleError(fmt.Errorf("invalid client type: %s", c),
+				http.StatusUnprocessableEntity, w)
+			return
+		}
+	}
+
+	if err := a.daemon.MapDirectory(
+		drive, dir, cl, isFlagSet(req, "force")); err != nil {
+		switch {
+		case errors.Is(err, daemon.ErrNotWatched):
+			// mapping in place, but directory not watched; still report
+			sendReply([]byte(err.Error()), http.StatusOK, w)
+			a.notifyChange()
+		case errors.Is(err, daemon.ErrLocked):
+			handleError(err, http.StatusLocked, w)
+		case errors.Is(err, daemon.ErrModified):
+			handleError(err, http.StatusConflict, w)
+		default:
+			handleError(err, http.StatusUnprocessableEntity, w)
+		}
+		return
+	}
+
+	sendReply([]byte(fmt.Sprintf(
+		"mapped directory %s to drive %d", getArg(req, "path"), drive)),
+		http.StatusOK, w)
+	a.notifyChange()
+}
+
+// unmapDir ends the directory mapping of a drive
+func (a *api) unmapDir(w http.ResponseWriter, req *http.Request) {
+
+	drive := getDrive(w, req)
+	if drive == -1 {
+		return
+	}
+
+	if a.daemon.GetDirectory(drive) == "" {
+		handleError(fmt.Errorf("no directory mapped to drive %d", drive),
+			http.StatusNotFound, w)
+		return
+	}
+
+	if err := a.daemon.UnmapDirectory(drive); handleError(
+		err, http.StatusUnprocessableEntity, w) {
+		return
+	}
+
+	sendReply([]byte(fmt.Sprintf("unmapped directory from drive %d", drive)),
+		http.StatusOK, w)
+}
+
+// repoPath resolves path p relative to the cartridge repository, making sure
+// the result does not point outside of the repository
+func (a *api) repoPath(p string) (string, error) {
+
+	if p == "" {
+		return "", fmt.Errorf("no directory specified")
+	}
+
+	rel := filepath.Clean(filepath.FromSlash(strings.TrimLeft(p, "/")))
+	if rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("directory outside of repository: %s", p)
+	}
+
+	return filepath.Join(a.repository, rel), nil
+}
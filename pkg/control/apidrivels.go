@@ -104,8 +104,12 @@ func WriteFileList(w io.Writer, c *base.Cartridge) {
 			fmt.Fprintf(w, "%-16s%8d  %-6v\n",
 				f.Name(), f.Size(), f.GetAnnotation("file-type"))
 		}
-		fmt.Fprintf(w, "\n%d of %d sectors used (%dkb free)\n\n",
+		fmt.Fprintf(w, "\n%d of %d sectors used (%dkb free)\n",
 			stats.Used(), stats.Sectors(), (stats.Sectors()-stats.Used())/2)
+		if fp, err := c.Fingerprint(); err == nil && fp != "" {
+			fmt.Fprintf(w, "fingerprint %s\n", fp)
+		}
+		fmt.Fprintln(w)
 	} else {
 		fmt.Fprintf(w, "\nerror listing files: %v\n\n", err)
 	}
@@ -0,0 +1,249 @@
+/*
+   OqtaDrive - Sinclair Microdrive emulator
+   Copyright (c) 2022, Alexander Vollschwitz
+
+   This file is part of OqtaDrive.
+
+   OqtaDrive is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   OqtaDrive is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with OqtaDrive. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package control
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"github.com/xelalexv/oqtadrive/pkg/daemon"
+	"github.com/xelalexv/oqtadrive/pkg/microdrive/base"
+	"github.com/xelalexv/oqtadrive/pkg/microdrive/client"
+	"github.com/xelalexv/oqtadrive/pkg/microdrive/if1"
+	"github.com/xelalexv/oqtadrive/pkg/util"
+)
+
+//
+func (a *api) getFile(w http.ResponseWriter, req *http.Request) {
+	a.withFile(w, req, func(cart *base.Cartridge, f *base.File, name string) {
+		data, err := f.Wrapped(getArg(req, "wrapper"))
+		if handleError(err, http.StatusUnprocessableEntity, w) {
+			return
+		}
+		sendBinaryReply(data, name, http.StatusOK, w)
+	})
+}
+
+//
+func (a *api) getListing(w http.ResponseWriter, req *http.Request) {
+	a.withFile(w, req, func(cart *base.Cartridge, f *base.File, name string) {
+		if cart.Client() != client.IF1 {
+			handleError(fmt.Errorf("listings only supported for IF1 cartridges"),
+				http.StatusUnprocessableEntity, w)
+			return
+		}
+		listing, err := if1.Listing(f)
+		if handleError(err, http.StatusUnprocessableEntity, w) {
+			return
+		}
+		sendReply([]byte(listing), http.StatusOK, w)
+	})
+}
+
+// withFile opens the file addressed by the request, and calls fn with it while
+// holding the lock on the cartridge
+func (a *api) withFile(w http.ResponseWriter, req *http.Request,
+	fn func(cart *base.Cartridge, f *base.File, name string)) {
+
+	a.withCartridge(w, req, func(cart *base.Cartridge) {
+
+		name := mux.Vars(req)["name"]
+
+		f, err := cart.FS().Open(name)
+		if handleError(err, http.StatusNotFound, w) {
+			return
+		}
+
+		fn(cart, f, name)
+	})
+}
+
+// withCartridge calls fn with the cartridge in the drive addressed by the
+// request, while holding the lock on the cartridge
+func (a *api) withCartridge(w http.ResponseWriter, req *http.Request,
+	fn func(cart *base.Cartridge)) {
+
+	drive := getDrive(w, req)
+	if drive == -1 {
+		return
+	}
+
+	if cart := a.lockCartridge(drive, w); cart != nil {
+		defer cart.Unlock()
+		fn(cart)
+	}
+}
+
+// lockCartridge returns the locked cartridge in the given drive. If the drive
+// is a hardware drive, busy, or empty, an error is sent and nil is returned.
+// Callers need to unlock the returned cartridge.
+func (a *api) lockCartridge(drive int, w http.ResponseWriter) *base.Cartridge {
+
+	if a.daemon.GetStatus(drive) == daemon.StatusHardware {
+		handleError(fmt.Errorf("drive %d is a hardware drive", drive),
+			http.StatusUnprocessableEntity, w)
+		return nil
+	}
+
+	cart, ok := a.daemon.GetCartridge(drive)
+	if !ok {
+		handleError(fmt.Errorf("drive %d busy", drive), http.StatusLocked, w)
+		return nil
+	}
+	if cart == nil {
+		handleError(fmt.Errorf("no cartridge in drive %d", drive),
+			http.StatusUnprocessableEntity, w)
+		return nil
+	}
+
+	return cart
+}
+
+//
+func (a *api) putFile(w http.ResponseWriter, req *http.Request) {
+
+	drive := getDrive(w, req)
+	if drive == -1 {
+		return
+	}
+
+	params := util.Params{}
+	for arg, key := range map[string]string{
+		"type":      "fileType",
+		"start":     "startAddress",
+		"line":      "lineNumber",
+		"dataspace": "dataspace",
+		"program":   "programLength",
+	} {
+		if val, err := getIntArg(req, arg, -1); err == nil {
+			params[key] = val
+		} else if getArg(req, arg) != "" {
+			handleError(fmt.Errorf("invalid value for %s: %v", arg, err),
+				http.StatusUnprocessableEntity, w)
+			return
+		}
+	}
+
+	data, err := io.ReadAll(
+		http.MaxBytesReader(nil, req.Body, maxUploadSize))
+	if handleError(err, http.StatusUnprocessableEntity, w) {
+		return
+	}
+	if handleError(req.Body.Close(), http.StatusInternalServerError, w) {
+		return
+	}
+
+	name := mux.Vars(req)["name"]
+
+	if handleUpdateError(a.daemon.UpdateCartridge(drive,
+		func(c *base.Cartridge) error {
+			return c.FS().Create(name, data, params)
+		}), drive, w) {
+		return
+	}
+
+	sendReply([]byte(
+		fmt.Sprintf("put %s into drive %d", name, drive)), http.StatusOK, w)
+	a.notifyChange()
+}
+
+//
+func (a *api) deleteFile(w http.ResponseWriter, req *http.Request) {
+
+	drive := getDrive(w, req)
+	if drive == -1 {
+		return
+	}
+
+	name := mux.Vars(req)["name"]
+
+	if handleUpdateError(a.daemon.UpdateCartridge(drive,
+		func(c *base.Cartridge) error {
+			return c.FS().Delete(name)
+		}), drive, w) {
+		return
+	}
+
+	sendReply([]byte(
+		fmt.Sprintf("deleted %s from drive %d", name, drive)), http.StatusOK, w)
+	a.notifyChange()
+}
+
+//
+func (a *api) renameFile(w http.ResponseWriter, req *http.Request) {
+
+	drive := getDrive(w, req)
+	if drive == -1 {
+		return
+	}
+
+	name := mux.Vars(req)["name"]
+	to := getArg(req, "to")
+	if to == "" {
+		handleError(fmt.Errorf("no new file name given"),
+			http.StatusUnprocessableEntity, w)
+		return
+	}
+
+	if handleUpdateError(a.daemon.UpdateCartridge(drive,
+		func(c *base.Cartridge) error {
+			return c.FS().Rename(name, to)
+		}), drive, w) {
+		return
+	}
+
+	sendReply([]byte(fmt.Sprintf(
+		"renamed %s to %s in drive %d", name, to, drive)), http.StatusOK, w)
+	a.notifyChange()
+}
+
+// handleUpdateError maps errors returned from updating a cartridge in the
+// daemon to suitable HTTP status codes
+func handleUpdateError(err error, drive int, w http.ResponseWriter) bool {
+
+	if err == nil {
+		return false
+	}
+
+	switch {
+	case errors.Is(err, daemon.ErrLocked):
+		return handleError(fmt.Errorf("drive %d busy", drive), http.StatusLocked, w)
+	case errors.Is(err, base.ErrFileNotFound):
+		return handleError(err, http.StatusNotFound, w)
+	case errors.Is(err, base.ErrFileExists):
+		return handleError(err, http.StatusConflict, w)
+	case errors.Is(err, base.ErrWriteProtected),
+		errors.Is(err, daemon.ErrHardwareDrive),
+		errors.Is(err, base.ErrNotFormatted),
+		errors.Is(err, daemon.ErrNoCartridge),
+		errors.Is(err, base.ErrInvalidName),
+		errors.Is(err, base.ErrFileTooLarge),
+		errors.Is(err, base.ErrNoSpace),
+		errors.Is(err, base.ErrNotSupported):
+		return handleError(err, http.StatusUnprocessableEntity, w)
+	}
+
+	return handleError(err, http.StatusInternalServerError, w)
+}
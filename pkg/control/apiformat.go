@@ -0,0 +1,92 @@
+/*
+   OqtaDrive - Sinclair Microdrive emulator
+   Copyright (c) 2022, Alexander Vollschwitz
+
+   This file is part of OqtaDrive.
+
+   OqtaDrive is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   OqtaDrive is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with OqtaDrive. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package control
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/xelalexv/oqtadrive/pkg/daemon"
+	"github.com/xelalexv/oqtadrive/pkg/microdrive"
+	"github.com/xelalexv/oqtadrive/pkg/microdrive/client"
+)
+
+//
+func (a *api) format(w http.ResponseWriter, req *http.Request) {
+
+	drive := getDrive(w, req)
+	if drive == -1 {
+		return
+	}
+
+	if a.daemon.GetStatus(drive) == daemon.StatusHardware {
+		handleError(fmt.Errorf("drive %d is a hardware drive", drive),
+			http.StatusUnprocessableEntity, w)
+		return
+	}
+
+	cl := client.GetClient(getArg(req, "client"))
+	if cl == client.UNKNOWN {
+		switch a.daemon.GetClient() {
+		case client.IF1.String():
+			cl = client.IF1
+		case client.QL.String():
+			cl = client.QL
+		default:
+			handleError(fmt.Errorf(
+				"no client connected, client type needs to be specified"),
+				http.StatusUnprocessableEntity, w)
+			return
+		}
+	}
+
+	sectors, err := getIntArg(req, "sectors", 0)
+	if handleError(err, http.StatusUnprocessableEntity, w) {
+		return
+	}
+	if sectors == 0 {
+		sectors = microdrive.MaxSectorCount(cl)
+	}
+
+	cart, err := microdrive.NewFormattedCartridge(
+		cl, getArg(req, "name"), sectors)
+	if handleError(err, http.StatusUnprocessableEntity, w) {
+		return
+	}
+
+	if err := a.daemon.SetCartridge(drive, cart, isFlagSet(req, "force")); err != nil {
+		if strings.Contains(err.Error(), "could not lock") {
+			handleError(fmt.Errorf("drive %d busy", drive), http.StatusLocked, w)
+		} else if strings.Contains(err.Error(), "is modified") {
+			handleError(fmt.Errorf(
+				"cartridge in drive %d is modified", drive), http.StatusConflict, w)
+		} else {
+			handleError(err, http.StatusInternalServerError, w)
+		}
+
+	} else {
+		sendReply([]byte(fmt.Sprintf(
+			"formatted cartridge with %d sectors in drive %d", sectors, drive)),
+			http.StatusOK, w)
+		a.notifyChange()
+	}
+}
@@ -26,6 +26,8 @@ import (
 	"net/http"
 	"strings"
 
+	"github.com/xelalexv/oqtadrive/pkg/daemon"
+	"github.com/xelalexv/oqtadrive/pkg/microdrive/base"
 	"github.com/xelalexv/oqtadrive/pkg/microdrive/format"
 	"github.com/xelalexv/oqtadrive/pkg/repo"
 	"github.com/xelalexv/oqtadrive/pkg/util"
@@ -50,22 +52,23 @@ func (a *api) load(w http.ResponseWriter, req *http.Request) {
 			return
 		}
 	} else {
-		in = http.MaxBytesReader(nil, req.Body, 1048576) // FIXME make constant
+		in = http.MaxBytesReader(nil, req.Body, maxUploadSize)
 	}
 
-	cr, err := format.NewCartReader(in, getArg(req, "compressor"))
+	if isFlagSet(req, "all") {
+		a.loadAll(drive, in, w, req)
+		return
+	}
+
+	cr, err := format.NewSniffingCartReader(in, getArg(req, "compressor"),
+		getArg(req, "type"), getArg(req, "entry"))
 	if err != nil {
 		handleError(err, http.StatusUnprocessableEntity, w)
 		return
 	}
 	defer cr.Close()
 
-	typ := getArg(req, "type")
-	if typ == "" {
-		typ = cr.Type()
-	}
-
-	reader, err := format.NewFormat(typ)
+	reader, err := format.NewFormat(cr.Type())
 	if handleError(err, http.StatusUnprocessableEntity, w) {
 		return
 	}
@@ -73,6 +76,7 @@ func (a *api) load(w http.ResponseWriter, req *http.Request) {
 	params := util.Params{
 		"name":     getArg(req, "name"),
 		"launcher": getArg(req, "launcher"),
+		"loader":   isFlagSet(req, "loader"),
 	}
 	cart, err := reader.Read(cr, true, isFlagSet(req, "repair"), params)
 	if err != nil {
@@ -98,6 +102,133 @@ func (a *api) load(w http.ResponseWriter, req *http.Request) {
 	} else {
 		sendReply([]byte(
 			fmt.Sprintf("loaded data into drive %d", drive)), http.StatusOK, w)
-		a.forceNotify <- true
+		a.notifyChange()
+	}
+}
+
+/*
+	loadAll loads all cartridges contained in the archive provided by in into
+	consecutive free drives, starting at drive. Free drives are those
+	that are neither hardware drives nor mapped to a directory, and are either
+	empty or hold an unmodified, unformatted cartridge. Archive entries are
+	loaded in order of their names. Nothing is loaded if there are not enough
+	free drives.
+*/
+func (a *api) loadAll(drive int, in io.ReadCloser, w http.ResponseWriter,
+	req *http.Request) {
+
+	defer in.Close()
+
+	comp := getArg(req, "compressor")
+	if comp == "" {
+		var err error
+		if in, comp, err = format.SniffCompressor(in); err != nil {
+			handleError(err, http.StatusUnprocessableEntity, w)
+			return
+		}
+	}
+
+	archive, err := format.NewArchive(in, comp)
+	if handleError(err, http.StatusUnprocessableEntity, w) {
+		return
+	}
+
+	entries := archive.CartridgeEntries()
+	if len(entries) == 0 {
+		handleError(fmt.Errorf("no cartridges in archive"),
+			http.StatusUnprocessableEntity, w)
+		return
+	}
+
+	var carts []*base.Cartridge
+	repair := isFlagSet(req, "repair")
+
+	for _, e := range entries {
+		cart, err := readArchiveEntry(archive, e, repair)
+		if err != nil {
+			handleError(fmt.Errorf("cartridge %s corrupted: %v", e, err),
+				http.StatusUnprocessableEntity, w)
+			return
+		}
+		carts = append(carts, cart)
+	}
+
+	drives := a.freeDrives(drive, len(carts))
+	if len(drives) < len(carts) {
+		handleError(fmt.Errorf(
+			"not enough free drives starting at drive %d: need %d, found %d",
+			drive, len(carts), len(drives)), http.StatusConflict, w)
+		return
+	}
+
+	var msg strings.Builder
+	var loadErr error
+
+	for ix, cart := range carts {
+		if loadErr = a.daemon.SetCartridge(drives[ix], cart, false); loadErr != nil {
+			loadErr = fmt.Errorf(
+				"loading %s into drive %d failed: %v", entries[ix], drives[ix], loadErr)
+			break
+		}
+		fmt.Fprintf(&msg, "loaded %s into drive %d\n", entries[ix], drives[ix])
+	}
+
+	if msg.Len() > 0 {
+		a.notifyChange()
+	}
+
+	if loadErr != nil {
+		msg.WriteString(loadErr.Error())
+		sendReply([]byte(msg.String()), http.StatusInternalServerError, w)
+		return
+	}
+
+	sendReply([]byte(strings.TrimSuffix(msg.String(), "\n")), http.StatusOK, w)
+}
+
+//
+func readArchiveEntry(a *format.Archive, entry string, repair bool) (
+	*base.Cartridge, error) {
+
+	cr, err := a.Open(entry)
+	if err != nil {
+		return nil, err
+	}
+	defer cr.Close()
+
+	reader, err := format.NewFormat(cr.Type())
+	if err != nil {
+		return nil, err
+	}
+
+	return reader.Read(cr, true, repair, nil)
+}
+
+// freeDrives returns up to count free drives, starting at drive, see loadAll
+func (a *api) freeDrives(drive, count int) []int {
+
+	var ret []int
+
+	for ix := drive; ix <= daemon.DriveCount && len(ret) < count; ix++ {
+
+		if a.daemon.GetDirectory(ix) != "" {
+			continue
+		}
+
+		switch a.daemon.GetStatus(ix) {
+
+		case daemon.StatusEmpty:
+			ret = append(ret, ix)
+
+		case daemon.StatusIdle:
+			if cart, ok := a.daemon.GetCartridge(ix); ok && cart != nil {
+				if !cart.IsFormatted() && !cart.IsModified() {
+					ret = append(ret, ix)
+				}
+				cart.Unlock()
+			}
+		}
 	}
+
+	return ret
 }
@@ -34,7 +34,7 @@ func (a *api) list(w http.ResponseWriter, req *http.Request) {
 		sendJSONReply(list, http.StatusOK, w)
 
 	} else {
-		strList := "\nDRIVE CARTRIDGE       STATE"
+		strList := "\nDRIVE CARTRIDGE       STATE  FINGERPRINT"
 		for ix, c := range list {
 			strList += fmt.Sprintf("\n  %d   %s", ix+1, c.String())
 		}
@@ -51,7 +51,8 @@ func (a *api) save(w http.ResponseWriter, req *http.Request) {
 
 	defer cart.Unlock()
 
-	writer, err := format.NewFormat(getArg(req, "type"))
+	typ := getArg(req, "type")
+	writer, err := format.NewFormat(typ)
 	if handleError(err, http.StatusUnprocessableEntity, w) {
 		return
 	}
@@ -62,7 +63,9 @@ func (a *api) save(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	cart.SetModified(false)
+	if !format.IsExport(typ) {
+		cart.SetModified(false)
+	}
 	w.WriteHeader(http.StatusOK)
 	w.Write(out.Bytes())
 }
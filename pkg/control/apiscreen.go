@@ -0,0 +1,102 @@
+/*
+   OqtaDrive - Sinclair Microdrive emulator
+   Copyright (c) 2022, Alexander Vollschwitz
+
+   This file is part of OqtaDrive.
+
+   OqtaDrive is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   OqtaDrive is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with OqtaDrive. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package control
+
+import (
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/xelalexv/oqtadrive/pkg/microdrive"
+	"github.com/xelalexv/oqtadrive/pkg/microdrive/base"
+	"github.com/xelalexv/oqtadrive/pkg/microdrive/format"
+	"github.com/xelalexv/oqtadrive/pkg/repo"
+	"github.com/xelalexv/oqtadrive/pkg/screen"
+)
+
+//
+func (a *api) getScreen(w http.ResponseWriter, req *http.Request) {
+
+	mode, err := getIntArg(req, "mode", 0)
+	if handleError(err, http.StatusUnprocessableEntity, w) {
+		return
+	}
+
+	a.withFile(w, req, func(cart *base.Cartridge, f *base.File, name string) {
+		img, err := microdrive.ScreenPNG(
+			cart.Client(), f, isFlagSet(req, "flash"), mode)
+		if handleError(err, http.StatusUnprocessableEntity, w) {
+			return
+		}
+		sendPNGReply(img, w)
+	})
+}
+
+//
+func (a *api) getThumbnail(w http.ResponseWriter, req *http.Request) {
+
+	if a.repository == "" {
+		handleError(fmt.Errorf("cartridge repository is not enabled"),
+			http.StatusServiceUnavailable, w)
+		return
+	}
+
+	path := getArg(req, "path")
+	if path == "" || strings.Contains(path, "..") {
+		handleError(fmt.Errorf("invalid path: '%s'", path),
+			http.StatusUnprocessableEntity, w)
+		return
+	}
+
+	_, typ, compressor := format.SplitNameTypeCompressor(path)
+	if typ != "z80" && typ != "sna" {
+		handleError(fmt.Errorf("thumbnails only available for Z80 & SNA"),
+			http.StatusUnprocessableEntity, w)
+		return
+	}
+
+	in, err := repo.Resolve(
+		fmt.Sprintf("%s://%s", repo.RefSchemaRepo, filepath.ToSlash(path)),
+		a.repository)
+	if handleError(err, http.StatusNotFound, w) {
+		return
+	}
+
+	cr, err := format.NewCartReader(in, compressor)
+	if handleError(err, http.StatusUnprocessableEntity, w) {
+		in.Close()
+		return
+	}
+	defer cr.Close()
+
+	data, err := format.NewZ80(typ == "sna").Screen(cr)
+	if handleError(err, http.StatusUnprocessableEntity, w) {
+		return
+	}
+
+	img, err := screen.SpectrumPNG(data, false)
+	if handleError(err, http.StatusUnprocessableEntity, w) {
+		return
+	}
+
+	sendPNGReply(img, w)
+}
@@ -24,6 +24,8 @@ import (
 	"fmt"
 	"strings"
 
+	log "github.com/sirupsen/logrus"
+
 	"github.com/xelalexv/oqtadrive/pkg/daemon"
 	"github.com/xelalexv/oqtadrive/pkg/microdrive/base"
 )
@@ -78,6 +80,7 @@ type Cartridge struct {
 	Formatted      bool   `json:"formatted"`
 	WriteProtected bool   `json:"writeProtected"`
 	Modified       bool   `json:"modified"`
+	Fingerprint    string `json:"fingerprint"`
 }
 
 //
@@ -86,6 +89,11 @@ func (c *Cartridge) fill(cart *base.Cartridge) {
 	c.Formatted = cart.IsFormatted()
 	c.WriteProtected = cart.IsWriteProtected()
 	c.Modified = cart.IsModified()
+	if fp, err := cart.Fingerprint(); err != nil {
+		log.Warnf("cannot determine cartridge fingerprint: %v", err)
+	} else {
+		c.Fingerprint = fp
+	}
 }
 
 //
@@ -100,7 +108,8 @@ func (c *Cartridge) equals(o *Cartridge) bool {
 		c.Status == o.Status &&
 		c.Formatted == o.Formatted &&
 		c.WriteProtected == o.WriteProtected &&
-		c.Modified == o.Modified
+		c.Modified == o.Modified &&
+		c.Fingerprint == o.Fingerprint
 }
 
 //
@@ -131,7 +140,17 @@ func (c *Cartridge) String() string {
 		mod = '*'
 	}
 
-	return fmt.Sprintf("%-16s%c%c%c", name, format, write, mod)
+	return fmt.Sprintf("%-16s%c%c%c    %s", name, format, write, mod,
+		ShortFingerprint(c.Fingerprint))
+}
+
+// ShortFingerprint returns the abbreviated form of fingerprint fp used in
+// listings
+func ShortFingerprint(fp string) string {
+	if len(fp) > 16 {
+		return fp[:16]
+	}
+	return fp
 }
 
 //
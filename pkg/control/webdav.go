@@ -0,0 +1,624 @@
+/*
+   OqtaDrive - Sinclair Microdrive emulator
+   Copyright (c) 2022, Alexander Vollschwitz
+
+   This file is part of OqtaDrive.
+
+   OqtaDrive is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   OqtaDrive is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with OqtaDrive. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package control
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/net/webdav"
+
+	"github.com/xelalexv/oqtadrive/pkg/daemon"
+	"github.com/xelalexv/oqtadrive/pkg/microdrive"
+	"github.com/xelalexv/oqtadrive/pkg/microdrive/base"
+	"github.com/xelalexv/oqtadrive/pkg/util"
+)
+
+// path prefix of WebDAV share
+const davPrefix = "/dav"
+
+// errors returned by the WebDAV file system; they wrap os.ErrPermission, so
+// that the WebDAV handler skips affected drives when listing
+var (
+	errDavHardware = fmt.Errorf("hardware drive: %w", os.ErrPermission)
+	errDavBusy     = fmt.Errorf("drive busy: %w", os.ErrPermission)
+	errDavReadOnly = fmt.Errorf("cartridge is write protected: %w", os.ErrPermission)
+)
+
+/*
+	webDAVHandler returns the handler for the WebDAV share. The share presents
+	drives 1 through 8 as directories, with the files of the cartridge in each
+	drive inside. Before passing a request on to the WebDAV handler, drives
+	addressed by the request are checked, and if a drive is a hardware drive or
+	busy, or a write request addresses a write protected cartridge, a suitable
+	error status is returned right away. This is necessary since the WebDAV
+	handler maps file system errors to fixed status codes per request method.
+*/
+func (a *api) webDAVHandler() http.Handler {
+
+	h := &webdav.Handler{
+		Prefix:     davPrefix,
+		FileSystem: &davFS{api: a},
+		LockSystem: webdav.NewMemLS(),
+		Logger: func(req *http.Request, err error) {
+			if err != nil {
+				log.Debugf("WebDAV %s %s: %v", req.Method, req.URL.Path, err)
+			}
+		},
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+
+		write := false
+		switch req.Method {
+		case "PUT", "DELETE", "MKCOL", "PROPPATCH", "MOVE":
+			write = true
+		}
+
+		if drive, _, err := davPath(strings.TrimPrefix(
+			req.URL.Path, davPrefix)); err == nil && drive > 0 {
+			if a.davCheckDrive(w, drive, write) {
+				return
+			}
+		}
+
+		if dst := req.Header.Get("Destination"); dst != "" {
+			if u, err := url.Parse(dst); err == nil {
+				if drive, _, err := davPath(strings.TrimPrefix(
+					u.Path, davPrefix)); err == nil && drive > 0 {
+					if a.davCheckDrive(w, drive, true) {
+						return
+					}
+				}
+			}
+		}
+
+		h.ServeHTTP(w, req)
+	})
+}
+
+// davCheckDrive checks whether drive can be accessed, and for write access,
+// whether its cartridge is writable. If not, an error status is sent and true
+// is returned.
+func (a *api) davCheckDrive(w http.ResponseWriter, drive int, write bool) bool {
+
+	switch a.daemon.GetStatus(drive) {
+
+	case daemon.StatusHardware:
+		http.Error(w, fmt.Sprintf("drive %d is a hardware drive", drive),
+			http.StatusForbidden)
+		return true
+
+	case daemon.StatusBusy:
+		http.Error(w, fmt.Sprintf("drive %d busy", drive), http.StatusLocked)
+		return true
+	}
+
+	if !write {
+		return false
+	}
+
+	cart, ok := a.daemon.GetCartridge(drive)
+	if !ok {
+		http.Error(w, fmt.Sprintf("drive %d busy", drive), http.StatusLocked)
+		return true
+	}
+	if cart == nil {
+		http.Error(w, fmt.Sprintf("no cartridge in drive %d", drive),
+			http.StatusForbidden)
+		return true
+	}
+	defer cart.Unlock()
+
+	if !cart.IsFormatted() {
+		http.Error(w, fmt.Sprintf("cartridge in drive %d is not formatted",
+			drive), http.StatusForbidden)
+		return true
+	}
+	if cart.IsWriteProtected() {
+		http.Error(w, fmt.Sprintf("cartridge in drive %d is write protected",
+			drive), http.StatusForbidden)
+		return true
+	}
+
+	return false
+}
+
+// davPath splits a path within the WebDAV share into drive and file name.
+// Drive is 0 for the root.
+func davPath(name string) (drive int, file string, err error) {
+
+	name = strings.TrimPrefix(path.Clean("/"+name), "/")
+	if name == "" {
+		return 0, "", nil
+	}
+
+	parts := strings.Split(name, "/")
+	if len(parts) > 2 {
+		return 0, "", os.ErrNotExist
+	}
+
+	drive, err = strconv.Atoi(parts[0])
+	if err != nil || drive < 1 || drive > daemon.DriveCount {
+		return 0, "", os.ErrNotExist
+	}
+
+	if len(parts) == 2 {
+		file = parts[1]
+	}
+	return drive, file, nil
+}
+
+// davFS implements webdav.FileSystem on top of the cartridges in the daemon
+type davFS struct {
+	api *api
+}
+
+//
+func (d *davFS) Mkdir(ctx context.Context, name string, perm os.FileMode) error {
+	if drive, file, err := davPath(name); err == nil && file == "" {
+		return os.ErrExist
+	} else if err == nil && drive > 0 {
+		return os.ErrPermission
+	}
+	return os.ErrNotExist
+}
+
+//
+func (d *davFS) OpenFile(ctx context.Context, name string, flag int,
+	perm os.FileMode) (webdav.File, error) {
+
+	drive, file, err := davPath(name)
+	if err != nil {
+		return nil, err
+	}
+
+	write := flag&(os.O_WRONLY|os.O_RDWR|os.O_CREATE|os.O_TRUNC) != 0
+
+	if drive == 0 {
+		if write {
+			return nil, os.ErrPermission
+		}
+		var entries []fs.FileInfo
+		for ix := 1; ix <= daemon.DriveCount; ix++ {
+			entries = append(entries, &davDirInfo{name: strconv.Itoa(ix)})
+		}
+		return &davDir{info: &davDirInfo{name: "/"}, entries: entries}, nil
+	}
+
+	if file == "" {
+		if write {
+			return nil, os.ErrPermission
+		}
+		return d.openDrive(drive)
+	}
+
+	var ret *davFile
+
+	err = d.withCartridge(drive, func(c *base.Cartridge) error {
+
+		cfs := base.NewCartridgeFS(c)
+
+		f, err := cfs.Open(file)
+		if err != nil {
+			if !write || flag&os.O_CREATE == 0 {
+				return err
+			}
+			ret = &davFile{fs: d, drive: drive,
+				info: &davFileInfo{name: file}, buf: &bytes.Buffer{}}
+			return nil
+		}
+		defer f.Close()
+
+		info, err := f.Stat()
+		if err != nil {
+			return err
+		}
+
+		ret = &davFile{fs: d, drive: drive, info: info}
+
+		if write {
+			ret.buf = &bytes.Buffer{}
+			if flag&os.O_TRUNC == 0 {
+				if _, err := io.Copy(ret.buf, f); err != nil {
+					return err
+				}
+			}
+		} else {
+			data, err := io.ReadAll(f)
+			if err != nil {
+				return err
+			}
+			ret.rd = bytes.NewReader(data)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+//
+func (d *davFS) openDrive(drive int) (webdav.File, error) {
+
+	ret := &davDir{info: &davDirInfo{name: strconv.Itoa(drive)}}
+
+	err := d.withCartridge(drive, func(c *base.Cartridge) error {
+		entries, err := base.NewCartridgeFS(c).ReadDir(".")
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			info, err := e.Info()
+			if err != nil {
+				return err
+			}
+			ret.entries = append(ret.entries, info)
+		}
+		return nil
+	})
+
+	if err != nil && err != os.ErrNotExist { // no cartridge means empty drive
+		return nil, err
+	}
+	return ret, nil
+}
+
+//
+func (d *davFS) RemoveAll(ctx context.Context, name string) error {
+
+	drive, file, err := davPath(name)
+	if err != nil {
+		return err
+	}
+	if file == "" {
+		return os.ErrPermission
+	}
+
+	return d.update(drive, func(c *base.Cartridge) error {
+		return c.FS().Delete(file)
+	})
+}
+
+//
+func (d *davFS) Rename(ctx context.Context, oldName, newName string) error {
+
+	from, oldFile, err := davPath(oldName)
+	if err != nil {
+		return err
+	}
+	to, newFile, err := davPath(newName)
+	if err != nil {
+		return err
+	}
+	if oldFile == "" || newFile == "" {
+		return os.ErrPermission
+	}
+
+	if from == to {
+		return d.update(from, func(c *base.Cartridge) error {
+			return c.FS().Rename(oldFile, newFile)
+		})
+	}
+
+	// moving between drives, retain file header settings
+	var data []byte
+	var params util.Params
+
+	if err := d.withCartridge(from, func(c *base.Cartridge) error {
+		f, err := c.FS().Open(oldFile)
+		if err != nil {
+			return os.ErrNotExist
+		}
+		if params, err = microdrive.FileParams(f); err != nil {
+			return err
+		}
+		data, err = f.Bytes()
+		return err
+	}); err != nil {
+		return err
+	}
+
+	if err := d.update(to, func(c *base.Cartridge) error {
+		return c.FS().Create(newFile, data, params)
+	}); err != nil {
+		return err
+	}
+
+	return d.update(from, func(c *base.Cartridge) error {
+		return c.FS().Delete(oldFile)
+	})
+}
+
+//
+func (d *davFS) Stat(ctx context.Context, name string) (os.FileInfo, error) {
+
+	drive, file, err := davPath(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if drive == 0 {
+		return &davDirInfo{name: "/"}, nil
+	}
+	if file == "" {
+		return &davDirInfo{name: strconv.Itoa(drive)}, nil
+	}
+
+	var ret os.FileInfo
+	err = d.withCartridge(drive, func(c *base.Cartridge) error {
+		var err error
+		ret, err = base.NewCartridgeFS(c).Stat(file)
+		return err
+	})
+	return ret, err
+}
+
+// withCartridge calls fn with the cartridge in drive, while holding the lock
+// on the cartridge
+func (d *davFS) withCartridge(drive int, fn func(c *base.Cartridge) error) error {
+
+	if d.api.daemon.GetStatus(drive) == daemon.StatusHardware {
+		return errDavHardware
+	}
+
+	cart, ok := d.api.daemon.GetCartridge(drive)
+	if !ok {
+		return errDavBusy
+	}
+	if cart == nil {
+		return os.ErrNotExist
+	}
+	defer cart.Unlock()
+
+	return fn(cart)
+}
+
+// update runs fn on the cartridge in drive via the daemon's update path, and
+// maps errors to file system errors where applicable
+func (d *davFS) update(drive int, fn func(c *base.Cartridge) error) error {
+
+	err := d.api.daemon.UpdateCartridge(drive, fn)
+	if err == nil {
+		d.api.notifyChange() // a single DAV request may cause several updates
+		return nil
+	}
+
+	switch {
+	case errors.Is(err, daemon.ErrLocked):
+		return errDavBusy
+	case errors.Is(err, daemon.ErrHardwareDrive):
+		return errDavHardware
+	case errors.Is(err, base.ErrWriteProtected):
+		return errDavReadOnly
+	case errors.Is(err, base.ErrFileNotFound):
+		return os.ErrNotExist
+	}
+	return err
+}
+
+// davFile is a file opened via the WebDAV share. Files opened for reading
+// hold a copy of the file content. Files opened for writing collect written
+// data in a buffer, and are stored to the cartridge when closed.
+type davFile struct {
+	fs    *davFS
+	drive int
+	info  fs.FileInfo
+	rd    *bytes.Reader
+	buf   *bytes.Buffer
+}
+
+//
+func (f *davFile) Read(p []byte) (int, error) {
+	if f.rd == nil {
+		return 0, os.ErrPermission
+	}
+	return f.rd.Read(p)
+}
+
+//
+func (f *davFile) Seek(offset int64, whence int) (int64, error) {
+	if f.rd == nil {
+		return 0, os.ErrPermission
+	}
+	return f.rd.Seek(offset, whence)
+}
+
+//
+func (f *davFile) Write(p []byte) (int, error) {
+	if f.buf == nil {
+		return 0, os.ErrPermission
+	}
+	return f.buf.Write(p)
+}
+
+//
+func (f *davFile) Readdir(count int) ([]fs.FileInfo, error) {
+	return nil, errors.New("not a directory")
+}
+
+//
+func (f *davFile) Stat() (fs.FileInfo, error) {
+	if f.buf != nil {
+		return &davFileInfo{name: f.info.Name(), size: int64(f.buf.Len())}, nil
+	}
+	return f.info, nil
+}
+
+// Close stores the file to its cartridge if it was opened for writing. An
+// existing file is replaced, retaining its file header settings.
+func (f *davFile) Close() error {
+
+	if f.buf == nil {
+		return nil
+	}
+
+	data := f.buf.Bytes()
+	f.buf = nil
+
+	return f.fs.update(f.drive, func(c *base.Cartridge) error {
+		var params util.Params
+		name := f.info.Name()
+		if existing, err := c.FS().Open(name); err == nil {
+			if params, err = microdrive.FileParams(existing); err != nil {
+				return err
+			}
+			if err := c.FS().Delete(name); err != nil {
+				return err
+			}
+		}
+		return c.FS().Create(name, data, params)
+	})
+}
+
+// davDir is the root of the share, or a drive
+type davDir struct {
+	info    fs.FileInfo
+	entries []fs.FileInfo
+	pos     int
+}
+
+//
+func (d *davDir) Read(p []byte) (int, error) {
+	return 0, errors.New("is a directory")
+}
+
+//
+func (d *davDir) Seek(offset int64, whence int) (int64, error) {
+	return 0, errors.New("is a directory")
+}
+
+//
+func (d *davDir) Write(p []byte) (int, error) {
+	return 0, os.ErrPermission
+}
+
+//
+func (d *davDir) Readdir(count int) ([]fs.FileInfo, error) {
+
+	rest := len(d.entries) - d.pos
+	if count > 0 && rest == 0 {
+		return nil, io.EOF
+	}
+	if count > 0 && count < rest {
+		rest = count
+	}
+
+	ret := d.entries[d.pos : d.pos+rest]
+	d.pos += rest
+	return ret, nil
+}
+
+//
+func (d *davDir) Stat() (fs.FileInfo, error) {
+	return d.info, nil
+}
+
+//
+func (d *davDir) Close() error {
+	return nil
+}
+
+//
+type davDirInfo struct {
+	name string
+}
+
+//
+func (i *davDirInfo) Name() string {
+	return i.name
+}
+
+//
+func (i *davDirInfo) Size() int64 {
+	return 0
+}
+
+//
+func (i *davDirInfo) Mode() fs.FileMode {
+	return fs.ModeDir | 0755
+}
+
+//
+func (i *davDirInfo) ModTime() time.Time {
+	return time.Time{}
+}
+
+//
+func (i *davDirInfo) IsDir() bool {
+	return true
+}
+
+//
+func (i *davDirInfo) Sys() interface{} {
+	return nil
+}
+
+// davFileInfo is the info of a file opened for writing
+type davFileInfo struct {
+	name string
+	size int64
+}
+
+//
+func (i *davFileInfo) Name() string {
+	return i.name
+}
+
+//
+func (i *davFileInfo) Size() int64 {
+	return i.size
+}
+
+//
+func (i *davFileInfo) Mode() fs.FileMode {
+	return 0644
+}
+
+//
+func (i *davFileInfo) ModTime() time.Time {
+	return time.Time{}
+}
+
+//
+func (i *davFileInfo) IsDir() bool {
+	return false
+}
+
+//
+func (i *davFileInfo) Sys() interface{} {
+	return nil
+}
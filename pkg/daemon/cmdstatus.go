@@ -90,6 +90,7 @@ func (c *command) status(d *Daemon) error {
 			}
 		}
 	} else if cart != nil {
+		d.syncDirectory(drive, cart)
 		if err := helper.AutoSave(drive, cart); err != nil {
 			log.Errorf("auto-saving drive %d failed: %v", drive, err)
 		}
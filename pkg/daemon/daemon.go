@@ -24,6 +24,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -47,6 +48,22 @@ const StatusHardware = "hardware"
 //
 var ErrDaemonStopped = errors.New("daemon stopped")
 
+// ErrUnchanged can be returned by an update function passed to
+// UpdateCartridge to signal that it did not change the cartridge
+var ErrUnchanged = errors.New("cartridge unchanged")
+
+// errors returned when a drive or its cartridge is not available for an
+// operation; they get wrapped with the drive number, so check for them with
+// errors.Is
+var (
+	ErrLocked        = errors.New("could not lock cartridge")
+	ErrNoCartridge   = errors.New("no cartridge")
+	ErrHardwareDrive = errors.New("hardware drive")
+	ErrInvalidDrive  = errors.New("invalid drive number")
+	ErrSameDrive     = errors.New("source and target drive are the same")
+	ErrModified      = errors.New("cartridge is modified")
+)
+
 // the daemon that manages communication with the Interface 1/QL
 type Daemon struct {
 	//
@@ -60,6 +77,9 @@ type Daemon struct {
 	mru        *mru
 	debugStart time.Time
 	//
+	dirDrives []*dirDrive
+	dirLock   sync.Mutex
+	//
 	ctrlRun chan func() error
 	ctrlAck chan error
 	//
@@ -74,6 +94,7 @@ func NewDaemon(port string, baudRate uint, force client.Client) *Daemon {
 		baudRate:    baudRate,
 		forceClient: force,
 		mru:         &mru{},
+		dirDrives:   make([]*dirDrive, DriveCount),
 		ctrlRun:     make(chan func() error),
 		ctrlAck:     make(chan error),
 		stop:        make(chan bool),
@@ -256,14 +277,156 @@ func (d *Daemon) UnloadCartridge(ix int, force bool) error {
 func (d *Daemon) SetCartridge(ix int, c *base.Cartridge, force bool) error {
 
 	if present, ok := d.GetCartridge(ix); !ok {
-		return fmt.Errorf("could not lock present cartridge")
+		return fmt.Errorf("%w in drive %d", ErrLocked, ix)
 
 	} else if !force && present != nil && present.IsModified() {
 		present.Unlock()
-		return fmt.Errorf("present cartridge is modified")
+		return fmt.Errorf("present %w", ErrModified)
 	}
 
 	d.setCartridge(ix, c)
+	d.autoSave(ix, c)
+
+	return nil
+}
+
+// CopyCartridge places a copy of the cartridge at slot from into slot to
+// (1-based). The copy keeps the modified state of the original.
+func (d *Daemon) CopyCartridge(from, to int, force bool) error {
+
+	src, dst, err := d.lockPair(from, to, force)
+	if err != nil {
+		return err
+	}
+	defer unlock(src, dst)
+
+	cp, err := microdrive.CloneCartridge(src)
+	if err != nil {
+		return err
+	}
+
+	d.setCartridge(to, cp)
+	d.autoSave(to, cp)
+
+	return nil
+}
+
+// MoveCartridge moves the cartridge at slot from into slot to (1-based). Slot
+// from receives a blank cartridge.
+func (d *Daemon) MoveCartridge(from, to int, force bool) error {
+
+	src, dst, err := d.lockPair(from, to, force)
+	if err != nil {
+		return err
+	}
+	defer unlock(src, dst)
+
+	blank, err := microdrive.NewCartridge(src.Client())
+	if err != nil {
+		return err
+	}
+
+	d.setCartridge(to, src)
+	d.setCartridge(from, blank)
+	src.SetAutoSaved(false)
+	d.autoSave(to, src)
+	d.autoSave(from, blank)
+
+	return nil
+}
+
+// SwapCartridges swaps the cartridges at slots a and b (1-based).
+func (d *Daemon) SwapCartridges(a, b int) error {
+
+	cartA, cartB, err := d.lockPair(a, b, true)
+	if err != nil {
+		return err
+	}
+	defer unlock(cartA, cartB)
+
+	if cartB == nil {
+		return fmt.Errorf("%w in drive %d", ErrNoCartridge, b)
+	}
+
+	d.setCartridge(a, cartB)
+	d.setCartridge(b, cartA)
+	cartA.SetAutoSaved(false)
+	cartB.SetAutoSaved(false)
+	d.autoSave(a, cartB)
+	d.autoSave(b, cartA)
+
+	return nil
+}
+
+// lockPair locks the cartridges at slots from and to (1-based), for copying or
+// moving a cartridge from one slot to the other. There needs to be a cartridge
+// at slot from, and unless force is set, the cartridge at slot to must not be
+// modified. Neither slot may be a hardware drive.
+func (d *Daemon) lockPair(from, to int, force bool) (
+	src, dst *base.Cartridge, err error) {
+
+	if from == to {
+		return nil, nil, ErrSameDrive
+	}
+
+	for _, ix := range []int{from, to} {
+		if ix < 1 || ix > DriveCount {
+			return nil, nil, fmt.Errorf("%w: %d", ErrInvalidDrive, ix)
+		}
+		if d.GetStatus(ix) == StatusHardware {
+			return nil, nil, fmt.Errorf(
+				"drive %d is a %w", ix, ErrHardwareDrive)
+		}
+	}
+
+	// always lock in same order, to avoid lock contention between concurrent
+	// calls for the same pair of slots
+	first, second := from, to
+	if first > second {
+		first, second = second, first
+	}
+
+	cFirst, ok := d.GetCartridge(first)
+	if !ok {
+		return nil, nil, fmt.Errorf("%w in drive %d", ErrLocked, first)
+	}
+	cSecond, ok := d.GetCartridge(second)
+	if !ok {
+		unlock(cFirst)
+		return nil, nil, fmt.Errorf("%w in drive %d", ErrLocked, second)
+	}
+
+	src, dst = cFirst, cSecond
+	if first != from {
+		src, dst = cSecond, cFirst
+	}
+
+	if src == nil {
+		err = fmt.Errorf("%w in drive %d", ErrNoCartridge, from)
+	} else if !force && dst != nil && dst.IsModified() {
+		err = fmt.Errorf("drive %d: %w", to, ErrModified)
+	}
+
+	if err != nil {
+		unlock(src, dst)
+		return nil, nil, err
+	}
+
+	return src, dst, nil
+}
+
+// unlock unlocks all given cartridges that are not nil
+func unlock(carts ...*base.Cartridge) {
+	for _, c := range carts {
+		if c != nil {
+			c.Unlock()
+		}
+	}
+}
+
+// autoSave auto-saves cartridge c at slot ix (1-based) if needed, or removes
+// the auto-save file for that slot if c is not formatted
+func (d *Daemon) autoSave(ix int, c *base.Cartridge) {
 
 	if c == nil || !c.IsFormatted() {
 		if err := helper.AutoRemove(ix); err != nil {
@@ -275,12 +438,12 @@ func (d *Daemon) SetCartridge(ix int, c *base.Cartridge, force bool) error {
 			log.Errorf("auto-saving drive %d failed: %v", ix, err)
 		}
 	}
-
-	return nil
 }
 
-//
+// setCartridge sets the cartridge at slot ix (1-based), ending any directory
+// mapping for that slot
 func (d *Daemon) setCartridge(ix int, c *base.Cartridge) {
+	d.unmapDirectory(ix)
 	if 0 < ix && ix <= len(d.cartridges) {
 		d.cartridges[ix-1].Store(c)
 	}
@@ -346,6 +509,47 @@ func (d *Daemon) GetCartridge(ix int) (*base.Cartridge, bool) {
 	return nil, true
 }
 
+// UpdateCartridge runs the update function on the cartridge at slot ix
+// (1-based), while holding the cartridge lock. When update succeeds, the
+// cartridge is marked as modified and gets auto-saved. If update returns
+// ErrUnchanged, the cartridge is left as is, and nil is returned.
+func (d *Daemon) UpdateCartridge(ix int, update func(*base.Cartridge) error) error {
+
+	if d.GetStatus(ix) == StatusHardware {
+		return fmt.Errorf("drive %d is a %w", ix, ErrHardwareDrive)
+	}
+
+	cart, ok := d.GetCartridge(ix)
+	if !ok {
+		return fmt.Errorf("%w in drive %d", ErrLocked, ix)
+	}
+	if cart == nil {
+		return fmt.Errorf("%w in drive %d", ErrNoCartridge, ix)
+	}
+	defer cart.Unlock()
+
+	if !cart.IsFormatted() {
+		return fmt.Errorf("drive %d: %w", ix, base.ErrNotFormatted)
+	}
+	if cart.IsWriteProtected() {
+		return fmt.Errorf("drive %d: %w", ix, base.ErrWriteProtected)
+	}
+
+	if err := update(cart); err == ErrUnchanged {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	cart.SetModified(true)
+	d.syncDirectory(ix, cart)
+	if err := helper.AutoSave(ix, cart); err != nil {
+		log.Errorf("auto-saving drive %d failed: %v", ix, err)
+	}
+
+	return nil
+}
+
 //
 func (d *Daemon) getCartridge(ix int) *base.Cartridge {
 	if 0 < ix && ix <= len(d.cartridges) {
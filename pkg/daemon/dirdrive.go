@@ -0,0 +1,245 @@
+/*
+   OqtaDrive - Sinclair Microdrive emulator
+   Copyright (c) 2022, Alexander Vollschwitz
+
+   This file is part of OqtaDrive.
+
+   OqtaDrive is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   OqtaDrive is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with OqtaDrive. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package daemon
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/xelalexv/oqtadrive/pkg/microdrive"
+	"github.com/xelalexv/oqtadrive/pkg/microdrive/base"
+	"github.com/xelalexv/oqtadrive/pkg/microdrive/client"
+	"github.com/xelalexv/oqtadrive/pkg/util"
+)
+
+// backoff for directory watcher, and delay for retrying a reload when the
+// cartridge is busy
+const dirBackoff = 2 * time.Second
+const dirRetry = 2 * time.Second
+
+// ErrNotWatched is returned by MapDirectory when the directory got mapped, but
+// watching it for changes failed
+var ErrNotWatched = errors.New(
+	"directory mapped, but changes in directory will not be picked up")
+
+// a drive that is backed by a host directory
+type dirDrive struct {
+	drive   int
+	sync    *microdrive.DirSync
+	watcher *util.DirWatcher
+	retry   *time.Timer
+}
+
+/*
+	MapDirectory loads a cartridge synthesized from the files in host directory
+	dir into slot ix (1-based), and keeps cartridge and directory in sync. When
+	files in the directory change, the cartridge is reloaded. When the cartridge
+	is changed by the client or via the API, the changes are written back to
+	the directory. If cl is unknown, the cartridge is created for the currently
+	connected client. The mapping ends when another cartridge is placed into the
+	slot.
+*/
+func (d *Daemon) MapDirectory(ix int, dir string, cl client.Client,
+	force bool) error {
+
+	if ix < 1 || ix > DriveCount {
+		return fmt.Errorf("%w: %d", ErrInvalidDrive, ix)
+	}
+	if d.GetStatus(ix) == StatusHardware {
+		return fmt.Errorf("drive %d is a %w", ix, ErrHardwareDrive)
+	}
+
+	if cl == client.UNKNOWN {
+		if !d.synced {
+			return fmt.Errorf(
+				"not connected to adapter, client type needs to be specified")
+		}
+		cl = d.conduit.client
+	}
+
+	if info, err := os.Stat(dir); err != nil {
+		return err
+	} else if !info.IsDir() {
+		return fmt.Errorf("not a directory: %s", dir)
+	}
+
+	sync := microdrive.NewDirSync(cl, dir)
+	cart, err := sync.Load()
+	if err != nil {
+		return err
+	}
+
+	if err := d.SetCartridge(ix, cart, force); err != nil {
+		return err
+	}
+
+	dd := &dirDrive{drive: ix, sync: sync}
+
+	d.dirLock.Lock()
+	d.dirDrives[ix-1] = dd
+	d.dirLock.Unlock()
+
+	log.WithFields(log.Fields{"drive": ix, "dir": dir}).Info(
+		"mapped directory")
+
+	if dd.watcher, err = util.NewDirWatcher(dir); err == nil {
+		err = dd.watcher.Start(dirBackoff,
+			func(fsnotify.Event) error { return nil },
+			func() error { return d.reloadDirectory(dd) })
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrNotWatched, err)
+	}
+
+	return nil
+}
+
+// UnmapDirectory ends the directory mapping for slot ix (1-based), if any.
+// The cartridge stays in the slot.
+func (d *Daemon) UnmapDirectory(ix int) error {
+	if ix < 1 || ix > DriveCount {
+		return fmt.Errorf("%w: %d", ErrInvalidDrive, ix)
+	}
+	d.unmapDirectory(ix)
+	return nil
+}
+
+// GetDirectory returns the host directory mapped to slot ix (1-based), or an
+// empty string if there is no mapping.
+func (d *Daemon) GetDirectory(ix int) string {
+	if dd := d.getDirDrive(ix); dd != nil {
+		return dd.sync.Dir()
+	}
+	return ""
+}
+
+//
+func (d *Daemon) getDirDrive(ix int) *dirDrive {
+	if 0 < ix && ix <= len(d.dirDrives) {
+		d.dirLock.Lock()
+		defer d.dirLock.Unlock()
+		return d.dirDrives[ix-1]
+	}
+	return nil
+}
+
+// unmapDirectory ends the directory mapping for slot ix (1-based), if any.
+// Must not be called from the directory watcher's go routine.
+func (d *Daemon) unmapDirectory(ix int) {
+
+	if ix < 1 || ix > len(d.dirDrives) {
+		return
+	}
+
+	d.dirLock.Lock()
+	dd := d.dirDrives[ix-1]
+	d.dirDrives[ix-1] = nil
+	if dd != nil && dd.retry != nil {
+		dd.retry.Stop()
+	}
+	d.dirLock.Unlock()
+
+	if dd == nil {
+		return
+	}
+
+	// stop watcher outside of lock, since its go routine may be waiting for it
+	if dd.watcher != nil {
+		dd.watcher.Stop()
+	}
+
+	log.WithFields(log.Fields{"drive": ix, "dir": dd.sync.Dir()}).Info(
+		"unmapped directory")
+}
+
+// reloadDirectory reloads the cartridge for directory drive dd if files in the
+// directory have changed. If the cartridge is busy, reloading is retried later.
+func (d *Daemon) reloadDirectory(dd *dirDrive) error {
+
+	if d.getDirDrive(dd.drive) != dd {
+		return nil // mapping has ended
+	}
+
+	cart, ok := d.GetCartridge(dd.drive)
+	if !ok {
+		log.WithField("drive", dd.drive).Debug(
+			"cartridge busy, retrying directory reload later")
+		d.dirLock.Lock()
+		if d.dirDrives[dd.drive-1] == dd {
+			dd.retry = time.AfterFunc(dirRetry, func() {
+				if err := d.reloadDirectory(dd); err != nil {
+					log.Errorf("error reloading directory: %v", err)
+				}
+			})
+		}
+		d.dirLock.Unlock()
+		return nil
+	}
+	defer unlock(cart)
+
+	if cart != d.getCartridge(dd.drive) || d.getDirDrive(dd.drive) != dd {
+		return nil // cartridge was replaced in the meantime
+	}
+
+	if changed, err := dd.sync.Changed(); err != nil || !changed {
+		return err
+	}
+
+	if cart != nil && cart.IsModified() {
+		log.WithField("drive", dd.drive).Warn(
+			"directory changed, but cartridge has changes not yet written back, not reloading")
+		return nil
+	}
+
+	fresh, err := dd.sync.Load()
+	if err != nil {
+		return err
+	}
+
+	log.WithFields(log.Fields{"drive": dd.drive, "dir": dd.sync.Dir()}).Info(
+		"reloading changed directory")
+
+	// stored directly, since setCartridge would end the mapping
+	d.cartridges[dd.drive-1].Store(fresh)
+	d.autoSave(dd.drive, fresh)
+
+	return nil
+}
+
+// syncDirectory writes changes of cartridge c at slot ix (1-based) back to the
+// mapped host directory, if any. Caller needs to hold the cartridge lock.
+func (d *Daemon) syncDirectory(ix int, c *base.Cartridge) {
+
+	dd := d.getDirDrive(ix)
+	if dd == nil || c == nil || !c.IsModified() {
+		return
+	}
+
+	if err := dd.sync.Store(c); err != nil {
+		log.Errorf("writing drive %d back to directory %s failed: %v",
+			ix, dd.sync.Dir(), err)
+	}
+}
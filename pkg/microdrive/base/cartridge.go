@@ -64,6 +64,9 @@ type Cartridge struct {
 	modified  bool
 	autosaved bool
 	//
+	fingerprint   string // cached fingerprint, valid if fingerprinted is set
+	fingerprinted bool
+	//
 	lock chan bool
 }
 
@@ -195,6 +198,7 @@ func (c *Cartridge) SetSectorAt(ix int, s Sector) {
 			c.name = s.Name()
 		}
 		c.modified = true
+		c.fingerprinted = false
 	} else {
 		log.Errorf("trying to set sector at invalid index %d", ix)
 	}
@@ -228,6 +232,7 @@ func (c *Cartridge) IsModified() bool {
 //
 func (c *Cartridge) SetModified(m bool) {
 	c.modified = m
+	c.fingerprinted = false
 	if m {
 		c.autosaved = false
 	}
@@ -0,0 +1,161 @@
+/*
+   OqtaDrive - Sinclair Microdrive emulator
+   Copyright (c) 2022, Alexander Vollschwitz
+
+   This file is part of OqtaDrive.
+
+   OqtaDrive is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   OqtaDrive is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with OqtaDrive. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package base
+
+import (
+	"fmt"
+	"strings"
+)
+
+// kinds of problems found by a file system check
+const (
+	ProblemMissingRecord   = "missing-record"
+	ProblemDuplicateRecord = "duplicate-record"
+	ProblemLengthMismatch  = "length-mismatch"
+	ProblemExtraRecord     = "extra-record"
+	ProblemNameMismatch    = "name-mismatch"
+	ProblemOrphanedBlock   = "orphaned-block"
+	ProblemFreeSector      = "free-sector"
+	ProblemBlockMismatch   = "block-mismatch"
+	ProblemMissingSector   = "missing-sector"
+	ProblemDuplicateZero   = "duplicate-zero-block"
+	ProblemMissingMap      = "missing-map"
+	ProblemDirectory       = "directory"
+)
+
+// Problem is a single problem found by a file system check
+type Problem struct {
+	Kind     string `json:"kind"`
+	File     string `json:"file,omitempty"`
+	Sector   int    `json:"sector"` // -1 if not related to a particular sector
+	Message  string `json:"message"`
+	Repaired bool   `json:"repaired"`
+}
+
+//
+func (p *Problem) String() string {
+	var sb strings.Builder
+	sb.WriteString(p.Kind)
+	if p.Sector > -1 {
+		sb.WriteString(fmt.Sprintf(", sector %d", p.Sector))
+	}
+	if p.File != "" {
+		sb.WriteString(fmt.Sprintf(", file '%s'", p.File))
+	}
+	sb.WriteString(": ")
+	sb.WriteString(p.Message)
+	if p.Repaired {
+		sb.WriteString(" [repaired]")
+	}
+	return sb.String()
+}
+
+// CheckReport is the result of a file system check
+type CheckReport struct {
+	Problems []*Problem `json:"problems"`
+}
+
+//
+func NewCheckReport() *CheckReport {
+	return &CheckReport{Problems: []*Problem{}}
+}
+
+// Add adds a problem of given kind; use empty file and sector -1 for problems
+// not related to a particular file or sector
+func (r *CheckReport) Add(kind, file string, sector int, repaired bool,
+	format string, args ...interface{}) {
+	r.Problems = append(r.Problems, &Problem{
+		Kind:     kind,
+		File:     file,
+		Sector:   sector,
+		Message:  fmt.Sprintf(format, args...),
+		Repaired: repaired,
+	})
+}
+
+// IsClean determines whether the check found no problems
+func (r *CheckReport) IsClean() bool {
+	return len(r.Problems) == 0
+}
+
+// Repaired returns the number of repaired problems
+func (r *CheckReport) Repaired() int {
+	ret := 0
+	for _, p := range r.Problems {
+		if p.Repaired {
+			ret++
+		}
+	}
+	return ret
+}
+
+//
+func (r *CheckReport) String() string {
+	if r.IsClean() {
+		return "no problems found"
+	}
+	var sb strings.Builder
+	for _, p := range r.Problems {
+		sb.WriteString(p.String())
+		sb.WriteString("\n")
+	}
+	sb.WriteString(fmt.Sprintf("\n%d problem(s) found, %d repaired",
+		len(r.Problems), r.Repaired()))
+	return sb.String()
+}
+
+// CheckHeaderNames reports sector headers whose cartridge name differs from the
+// name found in the majority of headers. If rename is not nil, it is called for
+// each such header to correct its name. The majority name is returned.
+func CheckHeaderNames(cart *Cartridge, r *CheckReport,
+	rename func(h Header, name string) error) string {
+
+	count := make(map[string]int)
+	for ix := 0; ix < cart.SectorCount(); ix++ {
+		if s := cart.GetSectorAt(ix); s != nil && s.Header() != nil {
+			count[s.Header().Name()]++
+		}
+	}
+
+	name := ""
+	for n, c := range count {
+		if c > count[name] || c == count[name] && n < name {
+			name = n
+		}
+	}
+
+	for ix := 0; ix < cart.SectorCount(); ix++ {
+		s := cart.GetSectorAt(ix)
+		if s == nil || s.Header() == nil || s.Header().Name() == name {
+			continue
+		}
+		wrong := s.Header().Name()
+		repaired := false
+		if rename != nil {
+			repaired = rename(s.Header(), name) == nil
+		}
+		r.Add(ProblemNameMismatch, "", s.Index(), repaired,
+			"cartridge name in sector header is '%s' instead of '%s'",
+			wrong, name)
+	}
+
+	return name
+}
@@ -0,0 +1,57 @@
+/*
+   OqtaDrive - Sinclair Microdrive emulator
+   Copyright (c) 2022, Alexander Vollschwitz
+
+   This file is part of OqtaDrive.
+
+   OqtaDrive is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   OqtaDrive is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with OqtaDrive. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package base
+
+import (
+	"fmt"
+	"strings"
+)
+
+// FileRevolutions holds the estimated number of cartridge revolutions needed
+// for loading a file, before and after defragmenting
+type FileRevolutions struct {
+	File   string  `json:"file"`
+	Before float64 `json:"before"`
+	After  float64 `json:"after"`
+}
+
+// DefragReport is the result of defragmenting a cartridge
+type DefragReport struct {
+	Files []*FileRevolutions `json:"files"`
+	Moved int                `json:"moved"` // number of records moved
+}
+
+//
+func (r *DefragReport) String() string {
+
+	if len(r.Files) == 0 {
+		return "no files on cartridge"
+	}
+
+	var sb strings.Builder
+	sb.WriteString("file        revolutions before   after\n")
+	for _, f := range r.Files {
+		sb.WriteString(fmt.Sprintf("%-10s  %18.1f  %6.1f\n",
+			f.File, f.Before, f.After))
+	}
+	sb.WriteString(fmt.Sprintf("\n%d record(s) moved", r.Moved))
+	return sb.String()
+}
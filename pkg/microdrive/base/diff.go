@@ -0,0 +1,348 @@
+/*
+   OqtaDrive - Sinclair Microdrive emulator
+   Copyright (c) 2022, Alexander Vollschwitz
+
+   This file is part of OqtaDrive.
+
+   OqtaDrive is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   OqtaDrive is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with OqtaDrive. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package base
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// kinds of differences found when comparing cartridges
+const (
+	DiffAdded   = "added"
+	DiffRemoved = "removed"
+	DiffChanged = "changed"
+)
+
+// ValueDiff is a property that differs between the left and right side of a
+// comparison
+type ValueDiff struct {
+	Key   string `json:"key"`
+	Left  string `json:"left"`
+	Right string `json:"right"`
+}
+
+//
+func (v *ValueDiff) String() string {
+	return fmt.Sprintf("%s %s -> %s", v.Key, v.Left, v.Right)
+}
+
+// FileDiff is a file that was added, removed, or changed
+type FileDiff struct {
+	File    string       `json:"file"`
+	Change  string       `json:"change"`
+	Size    int          `json:"size"` // size on side where file is present
+	Values  []*ValueDiff `json:"values,omitempty"`
+	Content bool         `json:"content"` // whether content differs
+}
+
+//
+func (f *FileDiff) String() string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("%-7s  %-10s", f.Change, f.File))
+	switch f.Change {
+	case DiffChanged:
+		var details []string
+		for _, v := range f.Values {
+			details = append(details, v.String())
+		}
+		if f.Content {
+			details = append(details, "content")
+		}
+		sb.WriteString("  ")
+		sb.WriteString(strings.Join(details, ", "))
+	default:
+		sb.WriteString(fmt.Sprintf("  %d bytes", f.Size))
+	}
+	return sb.String()
+}
+
+// SectorDiff is a sector that was added, removed, or changed
+type SectorDiff struct {
+	Sector int    `json:"sector"`
+	Change string `json:"change"`
+	Header bool   `json:"header"` // whether header differs
+	Record bool   `json:"record"` // whether record differs
+}
+
+//
+func (s *SectorDiff) String() string {
+	ret := fmt.Sprintf("%-7s  sector %3d", s.Change, s.Sector)
+	if s.Change == DiffChanged {
+		var parts []string
+		if s.Header {
+			parts = append(parts, "header")
+		}
+		if s.Record {
+			parts = append(parts, "record")
+		}
+		ret += "  " + strings.Join(parts, ", ")
+	}
+	return ret
+}
+
+// DiffReport is the result of comparing two cartridges
+type DiffReport struct {
+	Left    string        `json:"left"`
+	Right   string        `json:"right"`
+	Files   []*FileDiff   `json:"files"`
+	Sectors []*SectorDiff `json:"sectors,omitempty"`
+}
+
+// IsEmpty determines whether no differences were found
+func (r *DiffReport) IsEmpty() bool {
+	return len(r.Files) == 0 && len(r.Sectors) == 0
+}
+
+//
+func (r *DiffReport) String() string {
+
+	if r.IsEmpty() {
+		return "no differences"
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("--- %s\n+++ %s\n", r.Left, r.Right))
+	if len(r.Files) > 0 {
+		sb.WriteString("\n")
+		for _, f := range r.Files {
+			sb.WriteString(f.String())
+			sb.WriteString("\n")
+		}
+	}
+	if len(r.Sectors) > 0 {
+		sb.WriteString("\n")
+		for _, s := range r.Sectors {
+			sb.WriteString(s.String())
+			sb.WriteString("\n")
+		}
+	}
+	return strings.TrimSuffix(sb.String(), "\n")
+}
+
+// Diff compares cartridge left against cartridge right at file level. With
+// sectors set, headers and records of all sectors are compared as well, with
+// sectors matched by their sector number.
+func Diff(left, right *Cartridge, sectors bool) (*DiffReport, error) {
+
+	if left.Client() != right.Client() {
+		return nil, fmt.Errorf("cannot compare %s cartridge with %s cartridge",
+			left.Client(), right.Client())
+	}
+
+	ret := &DiffReport{
+		Left:  strings.TrimSpace(left.Name()),
+		Right: strings.TrimSpace(right.Name()),
+		Files: []*FileDiff{},
+	}
+
+	lFiles, err := fileInfos(left)
+	if err != nil {
+		return nil, err
+	}
+	rFiles, err := fileInfos(right)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range unionKeys(lFiles, rFiles) {
+
+		lf, inLeft := lFiles[name]
+		rf, inRight := rFiles[name]
+
+		switch {
+		case !inRight:
+			ret.Files = append(ret.Files,
+				&FileDiff{File: name, Change: DiffRemoved, Size: lf.Size()})
+		case !inLeft:
+			ret.Files = append(ret.Files,
+				&FileDiff{File: name, Change: DiffAdded, Size: rf.Size()})
+		default:
+			d, err := diffFile(left, right, name, lf, rf)
+			if err != nil {
+				return nil, err
+			}
+			if d != nil {
+				ret.Files = append(ret.Files, d)
+			}
+		}
+	}
+
+	if sectors {
+		ret.Sectors = diffSectors(left, right)
+	}
+
+	return ret, nil
+}
+
+//
+func diffFile(left, right *Cartridge, name string, lf, rf *FileInfo) (
+	*FileDiff, error) {
+
+	ret := &FileDiff{File: name, Change: DiffChanged, Size: rf.Size()}
+
+	if lf.Size() != rf.Size() {
+		ret.Values = append(ret.Values, &ValueDiff{Key: "size",
+			Left: fmt.Sprint(lf.Size()), Right: fmt.Sprint(rf.Size())})
+	}
+
+	keys := make(map[string]bool)
+	for k := range lf.Annotations {
+		keys[k] = true
+	}
+	for k := range rf.Annotations {
+		keys[k] = true
+	}
+	var sorted []string
+	for k := range keys {
+		sorted = append(sorted, k)
+	}
+	sort.Strings(sorted)
+
+	for _, k := range sorted {
+		l := lf.GetAnnotation(k).String()
+		r := rf.GetAnnotation(k).String()
+		if l != r {
+			ret.Values = append(ret.Values, &ValueDiff{Key: k, Left: l, Right: r})
+		}
+	}
+
+	lData, err := fileBytes(left, name)
+	if err != nil {
+		return nil, err
+	}
+	rData, err := fileBytes(right, name)
+	if err != nil {
+		return nil, err
+	}
+	ret.Content = !bytes.Equal(lData, rData)
+
+	if len(ret.Values) == 0 && !ret.Content {
+		return nil, nil
+	}
+	return ret, nil
+}
+
+//
+func diffSectors(left, right *Cartridge) []*SectorDiff {
+
+	lSecs := sectorsByNumber(left)
+	rSecs := sectorsByNumber(right)
+
+	var numbers []int
+	for n := range lSecs {
+		numbers = append(numbers, n)
+	}
+	for n := range rSecs {
+		if _, ok := lSecs[n]; !ok {
+			numbers = append(numbers, n)
+		}
+	}
+	sort.Ints(numbers)
+
+	ret := []*SectorDiff{}
+
+	for _, n := range numbers {
+
+		ls, inLeft := lSecs[n]
+		rs, inRight := rSecs[n]
+
+		switch {
+		case !inRight:
+			ret = append(ret, &SectorDiff{Sector: n, Change: DiffRemoved})
+		case !inLeft:
+			ret = append(ret, &SectorDiff{Sector: n, Change: DiffAdded})
+		default:
+			d := &SectorDiff{Sector: n, Change: DiffChanged}
+			d.Header = !bytes.Equal(
+				ls.Header().Demuxed(), rs.Header().Demuxed())
+			d.Record = !bytes.Equal(
+				recordBytes(ls.Record()), recordBytes(rs.Record()))
+			if d.Header || d.Record {
+				ret = append(ret, d)
+			}
+		}
+	}
+
+	return ret
+}
+
+// fileInfos returns the files on cartridge c, keyed by their name with any
+// padding removed
+func fileInfos(c *Cartridge) (map[string]*FileInfo, error) {
+	ret := make(map[string]*FileInfo)
+	if !c.IsFormatted() {
+		return ret, nil
+	}
+	_, files, err := c.FS().Ls()
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range files {
+		ret[strings.TrimSpace(f.Name())] = f
+	}
+	return ret, nil
+}
+
+//
+func fileBytes(c *Cartridge, name string) ([]byte, error) {
+	f, err := c.FS().Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return f.Bytes()
+}
+
+//
+func sectorsByNumber(c *Cartridge) map[int]Sector {
+	ret := make(map[int]Sector)
+	for ix := 0; ix < c.SectorCount(); ix++ {
+		if s := c.GetSectorAt(ix); s != nil && s.Header() != nil {
+			ret[s.Index()] = s
+		}
+	}
+	return ret
+}
+
+//
+func recordBytes(r Record) []byte {
+	if r == nil {
+		return nil
+	}
+	return r.Demuxed()
+}
+
+//
+func unionKeys(a, b map[string]*FileInfo) []string {
+	var ret []string
+	for k := range a {
+		ret = append(ret, k)
+	}
+	for k := range b {
+		if _, ok := a[k]; !ok {
+			ret = append(ret, k)
+		}
+	}
+	sort.Strings(ret)
+	return ret
+}
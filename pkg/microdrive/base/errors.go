@@ -0,0 +1,38 @@
+/*
+   OqtaDrive - Sinclair Microdrive emulator
+   Copyright (c) 2022, Alexander Vollschwitz
+
+   This file is part of OqtaDrive.
+
+   OqtaDrive is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   OqtaDrive is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with OqtaDrive. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package base
+
+import (
+	"errors"
+)
+
+// errors returned by file system operations on cartridges; implementations
+// wrap them with further details, so check for them with errors.Is
+var (
+	ErrFileNotFound   = errors.New("file not found")
+	ErrFileExists     = errors.New("file already exists")
+	ErrFileTooLarge   = errors.New("file too large")
+	ErrInvalidName    = errors.New("invalid file name")
+	ErrNoSpace        = errors.New("not enough space on cartridge")
+	ErrWriteProtected = errors.New("cartridge is write protected")
+	ErrNotFormatted   = errors.New("cartridge is not formatted")
+	ErrNotSupported   = errors.New("operation not supported")
+)
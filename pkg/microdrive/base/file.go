@@ -31,6 +31,12 @@ import (
 // ROM, the data section of a record may be longer than the file block length.
 const FileBlockLength = 512
 
+// wrappers for exporting file content
+const (
+	WrapperRaw    = "raw"    // plain file data
+	WrapperHeader = "header" // file data preceded by file header
+)
+
 //
 type FileImpl interface {
 
@@ -62,8 +68,13 @@ type File struct {
 	readPos int
 }
 
-//
+// Bytes returns the file data, without the file header. Like Read, it
+// consumes the file.
 func (f *File) Bytes() ([]byte, error) {
+	if f.readPos == 0 && f.Size() <= f.FileHeaderLength() {
+		f.readPos = f.Size() // empty file, nothing to read
+		return []byte{}, nil
+	}
 	b := make([]byte, f.Size())
 	if n, err := f.Read(b); err != nil && (err != io.EOF || n == 0) {
 		return nil, err
@@ -72,6 +83,61 @@ func (f *File) Bytes() ([]byte, error) {
 	}
 }
 
+// FirstRecord returns the first record of the file, which contains the file
+// header.
+func (f *File) FirstRecord() (Record, error) {
+	if len(f.records) == 0 || f.records[0] == nil {
+		return nil, fmt.Errorf("missing record at index 0")
+	}
+	return f.records[0], nil
+}
+
+// Header returns a copy of the file header contained at the start of the first
+// record.
+func (f *File) Header() ([]byte, error) {
+
+	r, err := f.FirstRecord()
+	if err != nil {
+		return nil, err
+	}
+
+	l := f.FileHeaderLength()
+	d := r.Data()
+	if len(d) < l {
+		return nil, fmt.Errorf("incomplete file header")
+	}
+
+	ret := make([]byte, l)
+	copy(ret, d)
+	return ret, nil
+}
+
+// Wrapped returns the file content in the given wrapper, either WrapperRaw or
+// WrapperHeader. An empty wrapper is the same as WrapperRaw.
+func (f *File) Wrapped(wrapper string) ([]byte, error) {
+
+	data, err := f.Bytes()
+	if err != nil {
+		return nil, err
+	}
+
+	switch wrapper {
+
+	case "", WrapperRaw:
+		return data, nil
+
+	case WrapperHeader:
+		h, err := f.Header()
+		if err != nil {
+			return nil, err
+		}
+		return append(h, data...), nil
+
+	default:
+		return nil, fmt.Errorf("unsupported wrapper: %s", wrapper)
+	}
+}
+
 //
 func (f *File) Read(p []byte) (int, error) {
 
@@ -0,0 +1,108 @@
+/*
+   OqtaDrive - Sinclair Microdrive emulator
+   Copyright (c) 2022, Alexander Vollschwitz
+
+   This file is part of OqtaDrive.
+
+   OqtaDrive is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   OqtaDrive is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with OqtaDrive. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package base
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"sort"
+	"strings"
+)
+
+/*
+	Fingerprint returns a canonical content hash of this cartridge, as a hex
+	encoded SHA-256 sum. It is computed over the used records of all files, in
+	order of file name and record number. Only the used part of each record,
+	i.e. the file header and file data it holds, is considered. The fingerprint
+	is therefore independent of the sector at which replay starts, the position
+	of files on the cartridge, left-over data in free records, and the name of
+	the cartridge. An unformatted cartridge has an empty fingerprint.
+
+	The fingerprint is cached, and only computed again after the cartridge has
+	been changed, i.e. a sector was set or the modified state changed.
+*/
+func (c *Cartridge) Fingerprint() (string, error) {
+
+	if !c.fingerprinted {
+		fp, err := c.computeFingerprint()
+		if err != nil {
+			return "", err
+		}
+		c.fingerprint = fp
+		c.fingerprinted = true
+	}
+
+	return c.fingerprint, nil
+}
+
+//
+func (c *Cartridge) computeFingerprint() (string, error) {
+
+	if !c.IsFormatted() {
+		return "", nil
+	}
+
+	_, infos, err := c.FS().Ls()
+	if err != nil {
+		return "", err
+	}
+
+	var names []string
+	for _, i := range infos {
+		names = append(names, strings.TrimSpace(i.Name()))
+	}
+	sort.Strings(names)
+
+	h := sha256.New()
+	length := make([]byte, 4)
+
+	for _, n := range names {
+
+		f, err := c.FS().Open(n)
+		if err != nil {
+			return "", err
+		}
+
+		h.Write([]byte(n))
+		h.Write([]byte{0})
+		binary.BigEndian.PutUint32(length, uint32(f.Size()))
+		h.Write(length)
+
+		for ix, r := range f.records {
+			used := f.Size() - ix*FileBlockLength
+			if used > FileBlockLength {
+				used = FileBlockLength
+			}
+			if r == nil || used <= 0 {
+				h.Write([]byte{0xff}) // missing record
+				continue
+			}
+			if d := r.Data(); len(d) < used {
+				h.Write(d)
+			} else {
+				h.Write(d[:used])
+			}
+		}
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,346 @@
+/*
+   OqtaDrive - Sinclair Microdrive emulator
+   Copyright (c) 2022, Alexander Vollschwitz
+
+   This file is part of OqtaDrive.
+
+   OqtaDrive is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   OqtaDrive is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with OqtaDrive. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package base
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/xelalexv/oqtadrive/pkg/util"
+)
+
+// NewCartridgeFS creates an io/fs adapter for cartridge c
+func NewCartridgeFS(c *Cartridge) *CartridgeFS {
+	return &CartridgeFS{cart: c}
+}
+
+/*
+	CartridgeFS adapts a cartridge to the io/fs interfaces, so that standard
+	tooling such as fs.WalkDir, fs.Glob, or http.FileServer can be used on it.
+	The file system is read only and flat, i.e. all files are located in the
+	root directory. Files whose names are not valid path elements, e.g. because
+	they contain a slash, are left out.
+
+	File sizes do not include file headers, and file info returned by Sys() are
+	the file's annotations. Files that are executable, i.e. Spectrum BASIC
+	programs with auto-run line or QL executables, have their execute bits set.
+
+	The adapter does not lock the cartridge. If the cartridge may get modified
+	concurrently, callers need to hold the lock while using the adapter and any
+	files opened via it.
+*/
+type CartridgeFS struct {
+	cart *Cartridge
+}
+
+// Open opens the named file. Content of a file is read in full when opening.
+func (c *CartridgeFS) Open(name string) (fs.File, error) {
+
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+
+	if name == "." {
+		entries, err := c.entries()
+		if err != nil {
+			return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+		}
+		return &cartridgeDir{entries: entries}, nil
+	}
+
+	info, err := c.stat(name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+
+	f, err := c.cart.FS().Open(name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	data, err := f.Bytes()
+	if err != nil {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: err}
+	}
+
+	return &cartridgeFile{info: info, Reader: bytes.NewReader(data)}, nil
+}
+
+// ReadDir reads the named directory, which can only be the root directory,
+// and returns its entries sorted by file name.
+func (c *CartridgeFS) ReadDir(name string) ([]fs.DirEntry, error) {
+
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+
+	if name != "." {
+		err := fs.ErrNotExist
+		if _, e := c.stat(name); e == nil {
+			err = errNotDir
+		}
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: err}
+	}
+
+	entries, err := c.entries()
+	if err != nil {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: err}
+	}
+
+	ret := make([]fs.DirEntry, len(entries))
+	for ix, e := range entries {
+		ret[ix] = e
+	}
+	return ret, nil
+}
+
+// Stat returns file info for the named file.
+func (c *CartridgeFS) Stat(name string) (fs.FileInfo, error) {
+
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrInvalid}
+	}
+
+	if name == "." {
+		return rootInfo, nil
+	}
+
+	info, err := c.stat(name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: err}
+	}
+	return info, nil
+}
+
+//
+func (c *CartridgeFS) stat(name string) (*cartridgeFileInfo, error) {
+
+	entries, err := c.entries()
+	if err != nil {
+		return nil, err
+	}
+
+	ix := sort.Search(len(entries), func(i int) bool {
+		return entries[i].name >= name
+	})
+	if ix < len(entries) && entries[ix].name == name {
+		return entries[ix], nil
+	}
+
+	return nil, fs.ErrNotExist
+}
+
+// entries returns file info for all files on the cartridge, sorted by name
+func (c *CartridgeFS) entries() ([]*cartridgeFileInfo, error) {
+
+	ret := []*cartridgeFileInfo{}
+
+	if !c.cart.IsFormatted() {
+		return ret, nil
+	}
+
+	_, files, err := c.cart.FS().Ls()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, fi := range files {
+
+		name := strings.TrimSpace(fi.Name())
+		if name == "." || !fs.ValidPath(name) || strings.Contains(name, "/") {
+			continue
+		}
+
+		f, err := c.cart.FS().Open(name)
+		if err != nil {
+			return nil, err
+		}
+
+		size := f.Size() - f.FileHeaderLength()
+		if size < 0 {
+			size = 0
+		}
+
+		mode := fs.FileMode(0444)
+		if isExecutable(fi.Annotations) {
+			mode |= 0111
+		}
+
+		ret = append(ret, &cartridgeFileInfo{
+			name: name,
+			size: int64(size),
+			mode: mode,
+			anno: fi.Annotations,
+		})
+	}
+
+	sort.Slice(ret, func(i, j int) bool { return ret[i].name < ret[j].name })
+	return ret, nil
+}
+
+// isExecutable determines from the annotations of a file whether it can be run
+// directly, i.e. is a Spectrum BASIC program with auto-run line, or a QL
+// executable
+func isExecutable(a util.Annotations) bool {
+	switch a.GetAnnotation("file-type").String() {
+	case "BASIC":
+		line := a.GetAnnotation("line")
+		return line.IsInt() && line.Int() < 0x8000
+	case "exec":
+		return true
+	}
+	return false
+}
+
+//
+var errNotDir = &notDirError{}
+
+//
+type notDirError struct{}
+
+//
+func (e *notDirError) Error() string {
+	return "not a directory"
+}
+
+//
+var rootInfo = &cartridgeFileInfo{name: ".", mode: fs.ModeDir | 0555}
+
+// cartridgeFileInfo implements fs.FileInfo and fs.DirEntry
+type cartridgeFileInfo struct {
+	name string
+	size int64
+	mode fs.FileMode
+	anno util.Annotations
+}
+
+//
+func (i *cartridgeFileInfo) Name() string {
+	return i.name
+}
+
+//
+func (i *cartridgeFileInfo) Size() int64 {
+	return i.size
+}
+
+//
+func (i *cartridgeFileInfo) Mode() fs.FileMode {
+	return i.mode
+}
+
+//
+func (i *cartridgeFileInfo) ModTime() time.Time {
+	return time.Time{}
+}
+
+//
+func (i *cartridgeFileInfo) IsDir() bool {
+	return i.mode.IsDir()
+}
+
+// Sys returns the annotations of the file, if any
+func (i *cartridgeFileInfo) Sys() interface{} {
+	if i.anno == nil {
+		return nil
+	}
+	return i.anno
+}
+
+//
+func (i *cartridgeFileInfo) Type() fs.FileMode {
+	return i.mode.Type()
+}
+
+//
+func (i *cartridgeFileInfo) Info() (fs.FileInfo, error) {
+	return i, nil
+}
+
+// cartridgeFile is an open file; it implements fs.File, io.Seeker, and
+// io.ReaderAt, as needed by http.FileServer
+type cartridgeFile struct {
+	*bytes.Reader
+	info *cartridgeFileInfo
+}
+
+//
+func (f *cartridgeFile) Stat() (fs.FileInfo, error) {
+	return f.info, nil
+}
+
+//
+func (f *cartridgeFile) Close() error {
+	return nil
+}
+
+// cartridgeDir is the opened root directory; it implements fs.ReadDirFile
+type cartridgeDir struct {
+	entries []*cartridgeFileInfo
+	pos     int
+}
+
+//
+func (d *cartridgeDir) Stat() (fs.FileInfo, error) {
+	return rootInfo, nil
+}
+
+//
+func (d *cartridgeDir) Read(p []byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: ".", Err: fs.ErrInvalid}
+}
+
+//
+func (d *cartridgeDir) Seek(offset int64, whence int) (int64, error) {
+	if offset == 0 && whence == io.SeekStart {
+		d.pos = 0
+		return 0, nil
+	}
+	return 0, &fs.PathError{Op: "seek", Path: ".", Err: fs.ErrInvalid}
+}
+
+//
+func (d *cartridgeDir) Close() error {
+	return nil
+}
+
+//
+func (d *cartridgeDir) ReadDir(n int) ([]fs.DirEntry, error) {
+
+	rest := len(d.entries) - d.pos
+	if n > 0 && rest == 0 {
+		return nil, io.EOF
+	}
+	if n > 0 && n < rest {
+		rest = n
+	}
+
+	ret := make([]fs.DirEntry, rest)
+	for ix := range ret {
+		ret[ix] = d.entries[d.pos+ix]
+	}
+	d.pos += rest
+
+	return ret, nil
+}
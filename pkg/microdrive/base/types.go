@@ -24,6 +24,8 @@ import (
 	"io"
 
 	"github.com/xelalexv/oqtadrive/pkg/microdrive/client"
+	"github.com/xelalexv/oqtadrive/pkg/microdrive/raw"
+	"github.com/xelalexv/oqtadrive/pkg/util"
 )
 
 //
@@ -55,6 +57,10 @@ type Header interface {
 	// Demuxed returns the plain data bytes of the header
 	Demuxed() []byte
 
+	// Block returns the header's data block, for accessing fields by name;
+	// changes to the block are not reflected in the muxed data
+	Block() *raw.Block
+
 	//
 	Flags() byte
 
@@ -83,6 +89,10 @@ type Record interface {
 	// Demuxed returns the plain data bytes of the record
 	Demuxed() []byte
 
+	// Block returns the record's data block, for accessing fields by name;
+	// changes to the block are not reflected in the muxed data
+	Block() *raw.Block
+
 	// Data returns the raw data of the record, without header data, but
 	// possibly including file header and extraneous data
 	Data() []byte
@@ -114,4 +124,18 @@ type FileSystem interface {
 
 	//
 	Open(name string) (*File, error)
+
+	// Create creates a new file with given name and data. Settings for the
+	// file header are passed via p, and are specific to the client.
+	Create(name string, data []byte, p util.Params) error
+
+	// Delete removes the file with given name.
+	Delete(name string) error
+
+	// Rename changes the name of file oldName to newName.
+	Rename(oldName, newName string) error
+
+	// Check checks the logical consistency of the file system. With repair
+	// set, problems that can be fixed without losing data are repaired.
+	Check(repair bool) (*CheckReport, error)
 }
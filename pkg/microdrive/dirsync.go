@@ -0,0 +1,302 @@
+/*
+   OqtaDrive - Sinclair Microdrive emulator
+   Copyright (c) 2022, Alexander Vollschwitz
+
+   This file is part of OqtaDrive.
+
+   OqtaDrive is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   OqtaDrive is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with OqtaDrive. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package microdrive
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/xelalexv/oqtadrive/pkg/microdrive/base"
+	"github.com/xelalexv/oqtadrive/pkg/microdrive/client"
+	"github.com/xelalexv/oqtadrive/pkg/util"
+)
+
+// NewDirSync creates a synchronizer between a cartridge for client cl and the
+// files in host directory dir.
+func NewDirSync(cl client.Client, dir string) *DirSync {
+	return &DirSync{client: cl, dir: dir, files: map[string]*syncedFile{}}
+}
+
+/*
+	DirSync synchronizes a cartridge with the files in a host directory. Only
+	regular files located directly in the directory are considered, and only if
+	their names are valid file names for the cartridge. Hidden files are
+	ignored.
+
+	A host file that starts with a matching file header, as written when getting
+	a file with header wrapper, is put onto the cartridge with that header. Any
+	other host file is taken as plain data, and becomes a code file on Spectrum
+	cartridges, and a data file on QL cartridges. When writing back to the host,
+	files are written with header, unless the host file held plain data and the
+	header settings did not change.
+*/
+type DirSync struct {
+	client client.Client
+	dir    string
+	files  map[string]*syncedFile // state as of last load or store
+}
+
+//
+type syncedFile struct {
+	raw     bool        // whether host file holds plain data without header
+	data    []byte      // file data without header
+	params  util.Params // parameters of the file header
+	skipped bool        // whether file could not be put onto the cartridge
+}
+
+// Dir returns the host directory of this synchronizer.
+func (s *DirSync) Dir() string {
+	return s.dir
+}
+
+// Client returns the client for which this synchronizer creates cartridges.
+func (s *DirSync) Client() client.Client {
+	return s.client
+}
+
+// Load creates a formatted cartridge with maximum sector count, holding the
+// files in the host directory. The cartridge is named after the directory.
+// Files that do not fit onto the cartridge are skipped.
+func (s *DirSync) Load() (*base.Cartridge, error) {
+
+	files, err := s.scan()
+	if err != nil {
+		return nil, err
+	}
+
+	name := strings.ToUpper(filepath.Base(s.dir))
+	if len(name) > 10 {
+		name = name[:10]
+	}
+
+	cart, err := NewFormattedCartridge(s.client, name, MaxSectorCount(s.client))
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for n := range files {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+
+	for _, n := range names {
+
+		f := files[n]
+
+		if err := cart.FS().Create(n, f.data, f.params); err != nil {
+			log.Warnf("skipping host file '%s': %v", n, err)
+			f.skipped = true
+			continue
+		}
+
+		// keep header settings as set by the file system, so that they can be
+		// compared when storing
+		if cf, err := cart.FS().Open(n); err != nil {
+			return nil, err
+		} else if f.params, err = FileParams(cf); err != nil {
+			return nil, err
+		}
+	}
+
+	cart.SetModified(false)
+	s.files = files
+
+	return cart, nil
+}
+
+// Changed determines whether the files in the host directory have changed
+// since the last load or store.
+func (s *DirSync) Changed() (bool, error) {
+
+	files, err := s.scan()
+	if err != nil {
+		return false, err
+	}
+
+	if len(files) != len(s.files) {
+		return true, nil
+	}
+
+	for n, f := range files {
+		prev, ok := s.files[n]
+		if !ok || f.raw != prev.raw || !bytes.Equal(f.data, prev.data) {
+			return true, nil
+		}
+		if !f.raw && !sameParams(f.params, prev.params) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// Store writes the files on the cartridge to the host directory. Only files
+// that have changed since the last load or store are written. Host files of
+// files that were removed from the cartridge are deleted. Afterwards, the
+// cartridge is no longer marked as modified.
+func (s *DirSync) Store(cart *base.Cartridge) error {
+
+	_, infos, err := cart.FS().Ls()
+	if err != nil {
+		return err
+	}
+
+	present := make(map[string]bool)
+
+	for _, info := range infos {
+
+		n := strings.TrimSpace(info.Name())
+		if n == "" || n == "." || n == ".." || strings.HasPrefix(n, ".") ||
+			strings.ContainsAny(n, "/\\") {
+			log.Warnf("not storing file '%s' to host, invalid name", n)
+			continue
+		}
+
+		f, err := cart.FS().Open(n)
+		if err != nil {
+			return err
+		}
+		data, err := f.Bytes()
+		if err != nil {
+			return err
+		}
+		params, err := FileParams(f)
+		if err != nil {
+			return err
+		}
+
+		present[n] = true
+
+		prev := s.files[n]
+		if prev != nil && !prev.skipped && bytes.Equal(prev.data, data) &&
+			sameParams(prev.params, params) {
+			continue
+		}
+
+		raw := prev != nil && prev.raw && sameParams(prev.params, params)
+
+		content := data
+		if !raw { // same as header wrapper; file has already been read
+			h, err := f.Header()
+			if err != nil {
+				return err
+			}
+			content = append(h, data...)
+		}
+
+		if err := os.WriteFile(filepath.Join(s.dir, n), content, 0644); err != nil {
+			return err
+		}
+		log.Debugf("stored file '%s' to host directory %s", n, s.dir)
+
+		s.files[n] = &syncedFile{raw: raw, data: data, params: params}
+	}
+
+	for n, f := range s.files {
+		if !present[n] && !f.skipped {
+			if err := os.Remove(filepath.Join(s.dir, n)); err != nil &&
+				!os.IsNotExist(err) {
+				return err
+			}
+			log.Debugf("removed file '%s' from host directory %s", n, s.dir)
+			delete(s.files, n)
+		}
+	}
+
+	cart.SetModified(false)
+	return nil
+}
+
+// scan reads all relevant files from the host directory
+func (s *DirSync) scan() (map[string]*syncedFile, error) {
+
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make(map[string]*syncedFile)
+
+	for _, e := range entries {
+
+		n := e.Name()
+		if !e.Type().IsRegular() || strings.HasPrefix(n, ".") {
+			continue
+		}
+		if err := ValidateFileName(s.client, n); err != nil {
+			log.Debugf("ignoring host file '%s': %v", n, err)
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(s.dir, n))
+		if err != nil {
+			return nil, err
+		}
+
+		f := &syncedFile{}
+		if d, p, ok := UnwrapFile(s.client, data); ok {
+			f.data, f.params = d, p
+		} else {
+			f.raw, f.data, f.params = true, data, util.Params{}
+			if s.client == client.QL {
+				if info, err := e.Info(); err == nil {
+					f.params["dateUpdate"] = int(info.ModTime().Unix())
+				}
+			}
+		}
+		ret[n] = f
+	}
+
+	return ret, nil
+}
+
+// sameParams compares file header parameters, ignoring update date, and for
+// other than BASIC files, program length
+func sameParams(a, b util.Params) bool {
+
+	basic := fmt.Sprint(a["fileType"]) == "0" && fmt.Sprint(b["fileType"]) == "0"
+
+	keys := make(map[string]bool)
+	for k := range a {
+		keys[k] = true
+	}
+	for k := range b {
+		keys[k] = true
+	}
+
+	for k := range keys {
+		if k == "dateUpdate" || (k == "programLength" && !basic) {
+			continue
+		}
+		if fmt.Sprint(a[k]) != fmt.Sprint(b[k]) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -27,6 +27,7 @@ import (
 	"github.com/xelalexv/oqtadrive/pkg/microdrive/client"
 	"github.com/xelalexv/oqtadrive/pkg/microdrive/if1"
 	"github.com/xelalexv/oqtadrive/pkg/microdrive/ql"
+	"github.com/xelalexv/oqtadrive/pkg/util"
 )
 
 //
@@ -45,6 +46,41 @@ func NewCartridge(cl client.Client) (*base.Cartridge, error) {
 	}
 }
 
+// MaxSectorCount returns the maximum number of sectors a cartridge for the
+// given client can hold.
+func MaxSectorCount(cl client.Client) int {
+
+	switch cl {
+
+	case client.IF1:
+		return if1.SectorCount
+
+	case client.QL:
+		return ql.SectorCount
+
+	default:
+		return 0
+	}
+}
+
+// NewFormattedCartridge creates a formatted cartridge for the given client,
+// with given name and number of sectors.
+func NewFormattedCartridge(cl client.Client, name string,
+	sectors int) (*base.Cartridge, error) {
+
+	switch cl {
+
+	case client.IF1:
+		return if1.NewFormattedCartridge(name, sectors)
+
+	case client.QL:
+		return ql.NewFormattedCartridge(name, sectors)
+
+	default:
+		return nil, fmt.Errorf("unsupported client type for cartridge: %d", cl)
+	}
+}
+
 //
 func NewSector(h base.Header, r base.Record) (base.Sector, error) {
 	return base.NewSector(h, r)
@@ -81,3 +117,123 @@ func NewRecord(cl client.Client, data []byte, raw bool) (base.Record, error) {
 		return nil, fmt.Errorf("unsupported client type for record: %d", cl)
 	}
 }
+
+// Defrag defragments the given cartridge, see if1.Defrag. Defragmenting is
+// only supported for IF1 cartridges.
+func Defrag(cart *base.Cartridge) (*base.DefragReport, error) {
+
+	switch cart.Client() {
+
+	case client.IF1:
+		return if1.Defrag(cart)
+
+	default:
+		return nil, fmt.Errorf(
+			"%w: defragmenting only supported for IF1 cartridges",
+			base.ErrNotSupported)
+	}
+}
+
+// FileParams returns the parameters for creating a copy of file f with the
+// same file header, e.g. on another cartridge
+func FileParams(f *base.File) (util.Params, error) {
+
+	r, err := f.FirstRecord()
+	if err != nil {
+		return nil, err
+	}
+
+	switch r.Client() {
+
+	case client.IF1:
+		return if1.FileParams(r), nil
+
+	case client.QL:
+		return ql.FileParams(r), nil
+
+	default:
+		return nil, fmt.Errorf("unsupported client type for file: %d", r.Client())
+	}
+}
+
+// ValidateFileName checks whether name is a valid file name for the given
+// client.
+func ValidateFileName(cl client.Client, name string) error {
+
+	switch cl {
+
+	case client.IF1:
+		return if1.ValidateName(name)
+
+	case client.QL:
+		return ql.ValidateName(name)
+
+	default:
+		return fmt.Errorf("unsupported client type: %d", cl)
+	}
+}
+
+// UnwrapFile checks whether data is file content preceded by a matching file
+// header of the given client, see if1.UnwrapFile and ql.UnwrapFile.
+func UnwrapFile(cl client.Client, data []byte) ([]byte, util.Params, bool) {
+
+	switch cl {
+
+	case client.IF1:
+		return if1.UnwrapFile(data)
+
+	case client.QL:
+		return ql.UnwrapFile(data)
+
+	default:
+		return nil, nil, false
+	}
+}
+
+// CloneCartridge creates a deep copy of the given cartridge. The copy has the
+// same name, write protection, and modified state as the original. Sectors are
+// copied as they are, defective ones included.
+func CloneCartridge(cart *base.Cartridge) (*base.Cartridge, error) {
+
+	ret, err := NewCartridge(cart.Client())
+	if err != nil {
+		return nil, err
+	}
+
+	for ix := 0; ix < cart.SectorCount(); ix++ {
+
+		sec := cart.GetSectorAt(ix)
+		if sec == nil {
+			continue
+		}
+
+		// validation errors are ignored, since a defective sector is still
+		// returned, and needs to be copied as is
+		hd, err := NewHeader(cart.Client(), sec.Header().Demuxed(), false)
+		if hd == nil {
+			return nil, fmt.Errorf("error copying header at index %d: %v", ix, err)
+		}
+
+		var rec base.Record
+		if r := sec.Record(); r != nil {
+			if rec, err = NewRecord(
+				cart.Client(), r.Demuxed(), false); rec == nil {
+				return nil, fmt.Errorf(
+					"error copying record at index %d: %v", ix, err)
+			}
+		}
+
+		s, err := NewSector(hd, rec)
+		if err != nil {
+			return nil, err
+		}
+		ret.SetSectorAt(ix, s)
+	}
+
+	ret.SetName(cart.Name())
+	ret.SetWriteProtected(cart.IsWriteProtected())
+	ret.SetModified(cart.IsModified())
+	ret.SeekToStart()
+
+	return ret, nil
+}
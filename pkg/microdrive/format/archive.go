@@ -0,0 +1,266 @@
+/*
+   OqtaDrive - Sinclair Microdrive emulator
+   Copyright (c) 2022, Alexander Vollschwitz
+
+   This file is part of OqtaDrive.
+
+   OqtaDrive is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   OqtaDrive is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with OqtaDrive. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package format
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/bodgit/sevenzip"
+	log "github.com/sirupsen/logrus"
+)
+
+/*
+	NewArchive reads the archive provided by r into memory. Valid compressors
+	are zip, 7z, and tar, with tar optionally compressed with gzip, bzip2, or
+	xz, e.g. tar.gz or tgz.
+*/
+func NewArchive(r io.ReadCloser, compressor string) (*Archive, error) {
+
+	ret := &Archive{compressor: compressor}
+	var err error
+
+	switch compressor {
+
+	case "zip", "7z":
+		err = ret.readZip(r)
+
+	default:
+		if isTar, comp := splitTar(compressor); isTar {
+			err = ret.readTar(r, comp)
+		} else {
+			err = fmt.Errorf("not a zip, 7z, or tar archive")
+		}
+	}
+
+	r.Close()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ret.files) == 0 {
+		return nil, fmt.Errorf("empty %s archive", compressor)
+	}
+
+	return ret, nil
+}
+
+// IsArchive determines whether compressor denotes an archive format that can
+// hold more than one entry.
+func IsArchive(compressor string) bool {
+	isTar, _ := splitTar(compressor)
+	return isTar || compressor == "zip" || compressor == "7z"
+}
+
+// Archive is a zip, 7z, or tar archive held in memory
+type Archive struct {
+	compressor string
+	files      []archiveFile
+}
+
+//
+type archiveFile struct {
+	name string
+	open func() (io.ReadCloser, error)
+}
+
+// Entries returns the names of all file entries in this archive, in archive
+// order.
+func (a *Archive) Entries() []string {
+	ret := make([]string, len(a.files))
+	for ix, f := range a.files {
+		ret[ix] = f.name
+	}
+	return ret
+}
+
+// CartridgeEntries returns the names of all entries in this archive that are
+// cartridges by their file extension, i.e. not snapshots or tape files, sorted
+// by name.
+func (a *Archive) CartridgeEntries() []string {
+	var ret []string
+	for _, f := range a.files {
+		switch _, typ, _ := SplitNameTypeCompressor(f.name); typ {
+		case "mdr", "mdv", "json":
+			ret = append(ret, f.name)
+		}
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+/*
+	Open opens the given entry of this archive for reading. The entry can be
+	given with its full path within the archive, or just its base name if that
+	is unique. If entry is empty, the first entry is opened.
+*/
+func (a *Archive) Open(entry string) (*CartReader, error) {
+
+	f, err := a.find(entry)
+	if err != nil {
+		return nil, err
+	}
+
+	rc, err := f.open()
+	if err != nil {
+		return nil, err
+	}
+
+	ret := &CartReader{
+		readCloser: rc,
+		compressor: a.compressor,
+		entries:    a.Entries(),
+	}
+	ret.name, ret.typ, _ = SplitNameTypeCompressor(f.name)
+
+	return ret, nil
+}
+
+//
+func (a *Archive) find(entry string) (*archiveFile, error) {
+
+	if entry == "" {
+		if len(a.files) > 1 {
+			log.Warnf(
+				"%s archive has more than one entry, using first; entries: %s",
+				a.compressor, strings.Join(a.Entries(), ", "))
+		}
+		return &a.files[0], nil
+	}
+
+	var match *archiveFile
+
+	for ix := range a.files {
+		f := &a.files[ix]
+		if f.name == entry {
+			return f, nil
+		}
+		if path.Base(f.name) == entry {
+			if match != nil {
+				return nil, fmt.Errorf(
+					"entry '%s' is ambiguous in archive, use full path", entry)
+			}
+			match = f
+		}
+	}
+
+	if match == nil {
+		return nil, fmt.Errorf("entry '%s' not found in archive; entries: %s",
+			entry, strings.Join(a.Entries(), ", "))
+	}
+
+	return match, nil
+}
+
+//
+func (a *Archive) readZip(r io.Reader) error {
+
+	var sponge bytes.Buffer
+	size, err := io.Copy(&sponge, r)
+	if err != nil {
+		return err
+	}
+
+	if a.compressor == "7z" {
+		zr, err := sevenzip.NewReader(bytes.NewReader(sponge.Bytes()), size)
+		if err != nil {
+			return err
+		}
+		for _, f := range zr.File {
+			if !f.FileInfo().IsDir() {
+				a.files = append(a.files, archiveFile{f.Name, f.Open})
+			}
+		}
+
+	} else {
+		zr, err := zip.NewReader(bytes.NewReader(sponge.Bytes()), size)
+		if err != nil {
+			return err
+		}
+		for _, f := range zr.File {
+			if !f.FileInfo().IsDir() {
+				a.files = append(a.files, archiveFile{f.Name, f.Open})
+			}
+		}
+	}
+
+	return nil
+}
+
+// readTar reads all regular files of a tar archive, which is compressed with
+// the given stream compressor, if any
+func (a *Archive) readTar(r io.ReadCloser, compressor string) error {
+
+	dr, err := decompress(r, compressor)
+	if err != nil {
+		return err
+	}
+
+	tr := tar.NewReader(dr)
+
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if hdr.Typeflag != tar.TypeReg && hdr.Typeflag != tar.TypeRegA {
+			continue
+		}
+
+		data, err := ioutil.ReadAll(tr)
+		if err != nil {
+			return err
+		}
+
+		a.files = append(a.files, archiveFile{hdr.Name,
+			func() (io.ReadCloser, error) {
+				return ioutil.NopCloser(bytes.NewReader(data)), nil
+			}})
+	}
+}
+
+// splitTar determines whether compressor denotes a tar archive, and if so,
+// which stream compressor is applied to it, if any
+func splitTar(compressor string) (bool, string) {
+	switch compressor {
+	case "tar":
+		return true, ""
+	case "tar.gz", "tar.gzip", "tgz":
+		return true, "gzip"
+	case "tar.bz2", "tar.bzip2", "tbz", "tbz2":
+		return true, "bzip2"
+	case "tar.xz", "txz":
+		return true, "xz"
+	}
+	return false, ""
+}
@@ -21,23 +21,31 @@
 package format
 
 import (
-	"archive/zip"
 	"bytes"
+	"compress/bzip2"
 	"compress/gzip"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"path/filepath"
 	"strings"
 
-	"github.com/bodgit/sevenzip"
-
 	log "github.com/sirupsen/logrus"
+	"github.com/ulikunitz/xz"
 )
 
 //
 func NewCartReader(r io.ReadCloser, compressor string) (*CartReader, error) {
+	return NewCartReaderForEntry(r, compressor, "")
+}
+
+// NewCartReaderForEntry creates a cartridge reader like NewCartReader. For
+// archives, the given entry is read, see Archive.Open.
+func NewCartReaderForEntry(r io.ReadCloser, compressor, entry string) (
+	*CartReader, error) {
 
-	log.WithField("compressor", compressor).Debug("cartridge reader requested")
+	log.WithFields(log.Fields{"compressor": compressor, "entry": entry}).Debug(
+		"cartridge reader requested")
 
 	var ret *CartReader
 	var err error
@@ -49,22 +57,31 @@ func NewCartReader(r io.ReadCloser, compressor string) (*CartReader, error) {
 	case "gz":
 		ret, err = getGZipReader(r)
 
-	case "zip":
-		ret, err = getZipReader(r, false)
-
-	case "7z":
-		ret, err = getZipReader(r, true)
+	case "bzip2", "bz2", "xz":
+		ret, err = getStreamReader(r, compressor)
 
 	case "":
-		ret = &CartReader{r, "", "", ""}
+		ret = &CartReader{readCloser: r}
+
+	default:
+		if IsArchive(compressor) {
+			var a *Archive
+			if a, err = NewArchive(r, compressor); err == nil {
+				ret, err = a.Open(entry)
+			}
+		}
+	}
+
+	if err != nil {
+		return nil, err
 	}
 
 	if ret == nil {
-		err = fmt.Errorf("unsupported compressor")
+		return nil, fmt.Errorf("unsupported compressor")
 	}
 
-	if err != nil {
-		return nil, err
+	if entry != "" && ret.entries == nil {
+		log.Warnf("ignoring entry '%s' for non-archive", entry)
 	}
 
 	log.WithFields(log.Fields{
@@ -75,6 +92,39 @@ func NewCartReader(r io.ReadCloser, compressor string) (*CartReader, error) {
 	return ret, nil
 }
 
+/*
+	NewSniffingCartReader creates a cartridge reader like NewCartReaderForEntry.
+	If compressor is empty, it is determined from the content. Cartridge format is
+	set to typ if given, otherwise it is taken from the name of the archive
+	entry, if any, and determined from the content as a last resort.
+*/
+func NewSniffingCartReader(r io.ReadCloser, compressor, typ, entry string) (
+	*CartReader, error) {
+
+	if compressor == "" {
+		var err error
+		if r, compressor, err = SniffCompressor(r); err != nil {
+			return nil, err
+		}
+	}
+
+	ret, err := NewCartReaderForEntry(r, compressor, entry)
+	if err != nil {
+		return nil, err
+	}
+
+	if typ != "" {
+		ret.typ = typ
+	} else if ret.typ == "" {
+		if _, err := ret.SniffType(); err != nil {
+			ret.Close()
+			return nil, err
+		}
+	}
+
+	return ret, nil
+}
+
 //
 type CartReader struct {
 	readCloser io.ReadCloser
@@ -82,6 +132,7 @@ type CartReader struct {
 	name       string
 	typ        string
 	compressor string
+	entries    []string
 }
 
 //
@@ -109,6 +160,36 @@ func (r *CartReader) Compressor() string {
 	return r.compressor
 }
 
+// Entries returns the names of all entries of the archive this reader reads
+// from, or nil if not reading from an archive.
+func (r *CartReader) Entries() []string {
+	return r.entries
+}
+
+/*
+	SniffType determines the cartridge format by looking at the content, see
+	SniffType function, and sets it as the type of this reader. For this, the
+	remaining content is read into memory, and served from there afterwards.
+*/
+func (r *CartReader) SniffType() (string, error) {
+
+	data, err := ioutil.ReadAll(r.readCloser)
+	if err != nil {
+		return "", err
+	}
+	if err := r.readCloser.Close(); err != nil {
+		return "", err
+	}
+	r.readCloser = ioutil.NopCloser(bytes.NewReader(data))
+
+	if r.typ = SniffType(data); r.typ == "" {
+		return "", fmt.Errorf("cannot determine cartridge format from content")
+	}
+
+	log.WithField("type", r.typ).Debug("cartridge format determined from content")
+	return r.typ, nil
+}
+
 //
 func getGZipReader(r io.ReadCloser) (*CartReader, error) {
 
@@ -125,55 +206,41 @@ func getGZipReader(r io.ReadCloser) (*CartReader, error) {
 }
 
 //
-func getZipReader(r io.ReadCloser, zip7 bool) (*CartReader, error) {
+func getStreamReader(r io.ReadCloser, compressor string) (*CartReader, error) {
 
-	var sponge bytes.Buffer
-	size, err := io.Copy(&sponge, r)
+	dr, err := decompress(r, compressor)
 	if err != nil {
 		return nil, err
 	}
-	r.Close()
-
-	ret := &CartReader{}
 
-	if zip7 {
-		zr, err := sevenzip.NewReader(bytes.NewReader(sponge.Bytes()), size)
-		if err != nil {
-			return nil, err
-		}
-		if len(zr.File) == 0 {
-			return nil, fmt.Errorf("empty 7-zip archive")
-		}
-		if len(zr.File) > 1 {
-			log.Warn("7-zip archive has more than one entry, using first")
-		}
+	return &CartReader{readCloser: dr, compressor: compressor}, nil
+}
 
-		ret.name, ret.typ, _ = SplitNameTypeCompressor(zr.File[0].Name)
-		ret.compressor = "7z"
-		ret.readCloser, err = zr.File[0].Open()
+// decompress wraps r into a reader that decompresses with the given stream
+// compressor, i.e. gzip, bzip2, or xz. If compressor is empty, r is returned.
+func decompress(r io.ReadCloser, compressor string) (io.ReadCloser, error) {
 
-	} else {
-		zr, err := zip.NewReader(bytes.NewReader(sponge.Bytes()), size)
-		if err != nil {
-			return nil, err
-		}
-		if len(zr.File) == 0 {
-			return nil, fmt.Errorf("empty zip archive")
-		}
-		if len(zr.File) > 1 {
-			log.Warn("zip archive has more than one entry, using first")
-		}
+	var dr io.Reader
+	var err error
 
-		ret.name, ret.typ, _ = SplitNameTypeCompressor(zr.File[0].Name)
-		ret.compressor = "zip"
-		ret.readCloser, err = zr.File[0].Open()
+	switch compressor {
+	case "":
+		return r, nil
+	case "gzip", "gz":
+		dr, err = gzip.NewReader(r)
+	case "bzip2", "bz2":
+		dr = bzip2.NewReader(r)
+	case "xz":
+		dr, err = xz.NewReader(r)
+	default:
+		err = fmt.Errorf("unsupported stream compressor: %s", compressor)
 	}
 
 	if err != nil {
 		return nil, err
 	}
 
-	return ret, nil
+	return &readCloser{Reader: dr, closer: r}, nil
 }
 
 //
@@ -200,16 +267,43 @@ func SplitNameTypeCompressor(file string) (name, typ, compressor string) {
 		case "z80":
 			fallthrough
 		case "sna":
+			fallthrough
+		case "tap":
+			fallthrough
+		case "tzx":
+			fallthrough
+		case "json":
 			typ = ext
 
 		case "gz":
 			fallthrough
 		case "gzip":
 			fallthrough
+		case "bz2":
+			fallthrough
+		case "bzip2":
+			fallthrough
+		case "xz":
+			fallthrough
 		case "zip":
 			fallthrough
 		case "7z":
+			fallthrough
+		case "tgz":
+			fallthrough
+		case "tbz":
+			fallthrough
+		case "tbz2":
+			fallthrough
+		case "txz":
 			compressor = ext
+
+		case "tar":
+			if compressor == "" {
+				compressor = ext
+			} else if isTar, _ := splitTar("tar." + compressor); isTar {
+				compressor = "tar." + compressor
+			}
 		}
 	}
 
@@ -46,6 +46,16 @@ type ReadWriter interface {
 	Writer
 }
 
+// IsExport determines whether writing a cartridge in the given format only
+// exports its content, i.e. the cartridge itself cannot be restored from it.
+func IsExport(typ string) bool {
+	switch strings.ToLower(typ) {
+	case "tap", "tzx":
+		return true
+	}
+	return false
+}
+
 //
 func NewFormat(typ string) (ReadWriter, error) {
 
@@ -63,6 +73,15 @@ func NewFormat(typ string) (ReadWriter, error) {
 	case "sna":
 		return NewZ80(true), nil
 
+	case "tap":
+		return NewTAP(), nil
+
+	case "tzx":
+		return NewTZX(), nil
+
+	case "json":
+		return NewJSON(), nil
+
 	default:
 		return nil, fmt.Errorf("unsupported cartridge format: %s", typ)
 	}
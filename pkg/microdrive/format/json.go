@@ -0,0 +1,416 @@
+/*
+   OqtaDrive - Sinclair Microdrive emulator
+   Copyright (c) 2022, Alexander Vollschwitz
+
+   This file is part of OqtaDrive.
+
+   OqtaDrive is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   OqtaDrive is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with OqtaDrive. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package format
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/xelalexv/oqtadrive/pkg/microdrive"
+	"github.com/xelalexv/oqtadrive/pkg/microdrive/base"
+	"github.com/xelalexv/oqtadrive/pkg/microdrive/client"
+	"github.com/xelalexv/oqtadrive/pkg/microdrive/raw"
+	"github.com/xelalexv/oqtadrive/pkg/util"
+)
+
+/*
+	JSON is a reader/writer for a lossless JSON representation of cartridges,
+	meant for inspecting and editing cartridges with common JSON tools. Besides
+	cartridge name, client type, and write protection, it contains every sector
+	with its position on the cartridge, and the demuxed bytes of its header and
+	record as hex strings. Additionally, the fields of header and record as
+	given by their block index are included in decoded form. Single byte fields
+	and two byte fields are numbers, longer fields are strings if they only
+	contain printable ASCII characters, and arrays of byte values otherwise.
+
+	When reading, the fields are applied on top of the bytes, so a field that
+	was changed takes precedence over the bytes. Fields can also be omitted.
+	Note that checksums are not recalculated, unless reading with repair. The
+	cartridge name is determined by the sector headers, the name at cartridge
+	level is only used when there are no sectors carrying a name.
+*/
+type JSON struct{}
+
+//
+func NewJSON() *JSON {
+	return &JSON{}
+}
+
+//
+type jsonCartridge struct {
+	Name           string       `json:"name"`
+	Client         string       `json:"client"`
+	WriteProtected bool         `json:"writeProtected"`
+	Sectors        []jsonSector `json:"sectors"`
+}
+
+//
+type jsonSector struct {
+	Position int        `json:"position"`
+	Header   *jsonBlock `json:"header"`
+	Record   *jsonBlock `json:"record"`
+}
+
+//
+type jsonBlock struct {
+	Bytes  string     `json:"bytes"`
+	Fields jsonFields `json:"fields,omitempty"`
+}
+
+// jsonFields holds the decoded fields of a block; when writing, fields are
+// kept in the order of their offset within the block
+type jsonFields struct {
+	keys   []string
+	values map[string]json.RawMessage
+}
+
+//
+func (f jsonFields) MarshalJSON() ([]byte, error) {
+
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+
+	for ix, k := range f.keys {
+		if ix > 0 {
+			buf.WriteByte(',')
+		}
+		key, err := json.Marshal(k)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(f.values[k])
+	}
+
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+//
+func (f *jsonFields) UnmarshalJSON(data []byte) error {
+	return json.Unmarshal(data, &f.values)
+}
+
+//
+func (j *JSON) Read(in io.Reader, strict, repair bool,
+	p util.Params) (*base.Cartridge, error) {
+
+	var jc jsonCartridge
+	if err := json.NewDecoder(in).Decode(&jc); err != nil {
+		return nil, fmt.Errorf("error reading JSON cartridge: %v", err)
+	}
+
+	cl := client.GetClient(jc.Client)
+	cart, err := microdrive.NewCartridge(cl)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, s := range jc.Sectors {
+
+		if s.Position < 0 || s.Position >= cart.SectorCount() {
+			return nil, fmt.Errorf("invalid sector position: %d", s.Position)
+		}
+		if s.Header == nil || s.Record == nil {
+			return nil, fmt.Errorf(
+				"incomplete sector at position %d", s.Position)
+		}
+
+		hd, err := readJSONHeader(cl, s.Header, repair)
+		if err != nil {
+			msg := fmt.Sprintf(
+				"defective header at position %d: %v", s.Position, err)
+			if strict || hd == nil {
+				return nil, fmt.Errorf(msg)
+			}
+			log.Warn(msg)
+		}
+
+		rec, err := readJSONRecord(cl, s.Record, repair)
+		if err != nil {
+			msg := fmt.Sprintf(
+				"defective record at position %d: %v", s.Position, err)
+			if strict || rec == nil {
+				return nil, fmt.Errorf(msg)
+			}
+			log.Warn(msg)
+		}
+
+		sec, err := microdrive.NewSector(hd, rec)
+		if err != nil {
+			msg := fmt.Sprintf(
+				"defective sector at position %d: %v", s.Position, err)
+			if strict {
+				return nil, fmt.Errorf(msg)
+			}
+			log.Warn(msg)
+		}
+
+		cart.SetSectorAt(s.Position, sec)
+	}
+
+	// name is normally determined by the sector headers
+	if strings.TrimSpace(cart.Name()) == "" {
+		cart.SetName(jc.Name)
+	}
+
+	cart.SeekToStart()
+	cart.RewindAccessIx(true)
+
+	log.Debugf("%d sectors loaded", len(jc.Sectors))
+	cart.SetWriteProtected(jc.WriteProtected)
+	cart.SetModified(false)
+
+	return cart, nil
+}
+
+// readJSONHeader creates a header from its JSON representation
+func readJSONHeader(cl client.Client, b *jsonBlock,
+	repair bool) (base.Header, error) {
+
+	data, err := b.decode(func(d []byte) (*raw.Block, error) {
+		hd, _ := microdrive.NewHeader(cl, d, false)
+		if hd == nil {
+			return nil, fmt.Errorf("invalid header")
+		}
+		return hd.Block(), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	hd, err := microdrive.NewHeader(cl, data, false)
+	if err != nil && repair && hd != nil {
+		if fx, ok := hd.(interface{ FixChecksum() error }); ok {
+			if e := fx.FixChecksum(); e == nil {
+				err = nil
+			}
+		}
+	}
+
+	return hd, err
+}
+
+// readJSONRecord creates a record from its JSON representation
+func readJSONRecord(cl client.Client, b *jsonBlock,
+	repair bool) (base.Record, error) {
+
+	data, err := b.decode(func(d []byte) (*raw.Block, error) {
+		rec, _ := microdrive.NewRecord(cl, d, false)
+		if rec == nil {
+			return nil, fmt.Errorf("invalid record")
+		}
+		return rec.Block(), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	rec, err := microdrive.NewRecord(cl, data, false)
+	if err != nil && repair && rec != nil {
+		if fx, ok := rec.(interface{ FixChecksums() error }); ok {
+			if e := fx.FixChecksums(); e == nil {
+				err = nil
+			}
+		}
+	}
+
+	return rec, err
+}
+
+// decode decodes the bytes of this block, and applies the fields on top. For
+// accessing the fields, a block is created from the bytes via newBlock.
+func (b *jsonBlock) decode(
+	newBlock func([]byte) (*raw.Block, error)) ([]byte, error) {
+
+	data, err := hex.DecodeString(b.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid bytes: %v", err)
+	}
+
+	if len(b.Fields.values) == 0 {
+		return data, nil
+	}
+
+	blk, err := newBlock(data)
+	if err != nil {
+		return nil, err
+	}
+
+	for k, v := range b.Fields.values {
+		if err := setJSONField(blk, k, v); err != nil {
+			return nil, err
+		}
+	}
+
+	return blk.Data, nil
+}
+
+// setJSONField sets field key in block blk to JSON value val
+func setJSONField(blk *raw.Block, key string, val json.RawMessage) error {
+
+	switch l := blk.FieldLength(key); l {
+
+	case 0:
+		return fmt.Errorf("unknown field: %s", key)
+
+	case 1, 2:
+		var n int
+		if err := json.Unmarshal(val, &n); err != nil {
+			return fmt.Errorf("field %s needs to be a number", key)
+		}
+		if n < 0 || n >= 1<<(8*l) {
+			return fmt.Errorf("value for field %s out of range: %d", key, n)
+		}
+		if l == 1 {
+			return blk.SetByte(key, byte(n))
+		}
+		return blk.SetInt(key, n)
+
+	default:
+		var s string
+		if err := json.Unmarshal(val, &s); err == nil {
+			return blk.SetString(key, s)
+		}
+		var a []byte
+		var ints []int
+		if err := json.Unmarshal(val, &ints); err != nil {
+			return fmt.Errorf(
+				"field %s needs to be a string or an array of bytes", key)
+		}
+		for _, i := range ints {
+			if i < 0 || i > 255 {
+				return fmt.Errorf("byte value for field %s out of range: %d",
+					key, i)
+			}
+			a = append(a, byte(i))
+		}
+		return blk.SetSlice(key, a)
+	}
+}
+
+//
+func (j *JSON) Write(cart *base.Cartridge, out io.Writer, p util.Params) error {
+
+	jc := jsonCartridge{
+		Name:           cart.Name(),
+		Client:         jsonClient(cart.Client()),
+		WriteProtected: cart.IsWriteProtected(),
+		Sectors:        []jsonSector{},
+	}
+
+	for ix := 0; ix < cart.SectorCount(); ix++ {
+
+		sec := cart.GetSectorAt(ix)
+		if sec == nil {
+			continue
+		}
+
+		hd, err := newJSONBlock(sec.Header().Block())
+		if err != nil {
+			return err
+		}
+		rec, err := newJSONBlock(sec.Record().Block())
+		if err != nil {
+			return err
+		}
+
+		jc.Sectors = append(jc.Sectors,
+			jsonSector{Position: ix, Header: hd, Record: rec})
+	}
+
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(jc)
+}
+
+// newJSONBlock creates the JSON representation of block blk
+func newJSONBlock(blk *raw.Block) (*jsonBlock, error) {
+
+	ret := &jsonBlock{
+		Bytes:  hex.EncodeToString(blk.Data),
+		Fields: jsonFields{values: make(map[string]json.RawMessage)},
+	}
+
+	for _, k := range blk.Fields() {
+
+		if len(blk.GetSlice(k)) != blk.FieldLength(k) {
+			continue // field lies beyond end of short block
+		}
+
+		var v interface{}
+
+		switch blk.FieldLength(k) {
+		case 1:
+			v = blk.GetByte(k)
+		case 2:
+			v = blk.GetInt(k)
+		default:
+			if b := blk.GetSlice(k); isPrintable(b) {
+				v = string(b)
+			} else {
+				ints := make([]int, len(b))
+				for ix := range b {
+					ints[ix] = int(b[ix])
+				}
+				v = ints
+			}
+		}
+
+		val, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		ret.Fields.keys = append(ret.Fields.keys, k)
+		ret.Fields.values[k] = val
+	}
+
+	return ret, nil
+}
+
+// jsonClient returns the name of client cl as accepted by client.GetClient
+func jsonClient(cl client.Client) string {
+	switch cl {
+	case client.IF1:
+		return "if1"
+	case client.QL:
+		return "ql"
+	default:
+		return ""
+	}
+}
+
+// isPrintable determines whether b only contains printable ASCII characters
+func isPrintable(b []byte) bool {
+	for _, c := range b {
+		if c < 0x20 || c > 0x7e {
+			return false
+		}
+	}
+	return true
+}
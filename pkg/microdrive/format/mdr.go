@@ -146,8 +146,10 @@ func (m *MDR) Write(cart *base.Cartridge, out io.Writer, p util.Params) error {
 
 	cart.SeekToStart()
 
+	// visit each slot exactly once, skipping unformatted ones, so that
+	// cartridges with fewer sectors than slots are not written repeatedly
 	for ix := 0; ix < cart.SectorCount(); ix++ {
-		if sec := cart.GetNextSector(); sec != nil {
+		if sec := cart.GetSectorAt(cart.AdvanceAccessIx(false)); sec != nil {
 
 			if _, err := out.Write(
 				sec.Header().Demuxed()[raw.SyncPatternLength:]); err != nil {
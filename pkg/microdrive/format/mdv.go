@@ -140,15 +140,21 @@ func (m *MDV) Write(cart *base.Cartridge, out io.Writer, p util.Params) error {
 		padding[ix] = 0x5a
 	}
 
+	if !cart.IsFormatted() {
+		return fmt.Errorf("cartridge is not formatted")
+	}
+
 	cart.SeekToStart()
 	cart.AdvanceAccessIx(false)
 
+	// visit each slot exactly once, skipping unformatted ones; cartridges with
+	// fewer sectors result in shorter MDV files
 	for ix := 0; ix < cart.SectorCount(); ix++ {
 
-		sec := cart.GetPreviousSector()
+		sec := cart.GetSectorAt(cart.RewindAccessIx(false))
 
-		if sec == nil { // MDV requires all sectors; FIXME: add blank one
-			return fmt.Errorf("missing sector %d", ix)
+		if sec == nil {
+			continue
 		}
 
 		missing := MDVSectorLength
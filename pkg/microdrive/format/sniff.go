@@ -0,0 +1,221 @@
+/*
+   OqtaDrive - Sinclair Microdrive emulator
+   Copyright (c) 2022, Alexander Vollschwitz
+
+   This file is part of OqtaDrive.
+
+   OqtaDrive is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   OqtaDrive is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with OqtaDrive. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package format
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"io/ioutil"
+)
+
+// MDRSectorLength is the length of a sector in an MDR file; MDR files end with
+// an additional byte for the write protection flag
+const MDRSectorLength = 543
+
+// SNALength is the length of a 48k SNA snapshot file
+const SNALength = 49179
+
+// magic bytes of supported compressors
+var compressorMagic = []struct {
+	compressor string
+	magic      []byte
+}{
+	{"gzip", []byte{0x1f, 0x8b}},
+	{"zip", []byte("PK\x03\x04")},
+	{"7z", []byte{'7', 'z', 0xbc, 0xaf, 0x27, 0x1c}},
+	{"bzip2", []byte("BZh")},
+	{"xz", []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}},
+}
+
+// stream compressors that can be combined with tar
+var tarCompressors = map[string]string{
+	"":      "tar",
+	"gzip":  "tar.gz",
+	"bzip2": "tar.bz2",
+	"xz":    "tar.xz",
+}
+
+// offset and magic of the ustar signature in a tar header
+const tarMagicOffset = 257
+
+var tarMagic = []byte("ustar")
+
+// magic bytes of the TZX format
+var tzxMagic = []byte("ZXTape!\x1a")
+
+/*
+	SniffCompressor determines the compressor of the data in r by looking at
+	its first bytes. It returns a reader that yields all of the data, including
+	the bytes looked at, and the compressor, which is empty if the data is not
+	compressed with any of the supported compressors. Tar archives are also
+	recognized, plain or compressed with gzip, bzip2, or xz. For checking the
+	latter, compressed data is read into memory.
+*/
+func SniffCompressor(r io.ReadCloser) (io.ReadCloser, string, error) {
+
+	br := bufio.NewReader(r)
+	head, err := br.Peek(tarMagicOffset + len(tarMagic))
+	if err != nil && err != io.EOF {
+		return nil, "", err
+	}
+
+	ret := &readCloser{Reader: br, closer: r}
+
+	if isTarHeader(head) {
+		return ret, "tar", nil
+	}
+
+	for _, m := range compressorMagic {
+		if bytes.HasPrefix(head, m.magic) {
+			if _, ok := tarCompressors[m.compressor]; !ok {
+				return ret, m.compressor, nil
+			}
+			return sniffTar(br, r, m.compressor)
+		}
+	}
+
+	return ret, "", nil
+}
+
+// sniffTar checks whether the data in br, compressed with the given stream
+// compressor, is a tar archive
+func sniffTar(br io.Reader, closer io.Closer, compressor string) (
+	io.ReadCloser, string, error) {
+
+	data, err := ioutil.ReadAll(br)
+	if err != nil {
+		return nil, "", err
+	}
+
+	ret := &readCloser{Reader: bytes.NewReader(data), closer: closer}
+
+	dr, err := decompress(ioutil.NopCloser(bytes.NewReader(data)), compressor)
+	if err != nil {
+		return ret, compressor, nil
+	}
+
+	head := make([]byte, tarMagicOffset+len(tarMagic))
+	if _, err := io.ReadFull(dr, head); err == nil && isTarHeader(head) {
+		return ret, tarCompressors[compressor], nil
+	}
+
+	return ret, compressor, nil
+}
+
+// isWriteProtectFlag determines whether b is a valid value for the write
+// protection byte at the end of an MDR file; OqtaDrive writes 0xff for write
+// protected cartridges, other emulators 0x01
+func isWriteProtectFlag(b byte) bool {
+	return b == 0x00 || b == 0x01 || b == 0xff
+}
+
+//
+func isTarHeader(head []byte) bool {
+	return len(head) >= tarMagicOffset+len(tarMagic) &&
+		bytes.Equal(head[tarMagicOffset:tarMagicOffset+len(tarMagic)], tarMagic)
+}
+
+/*
+	SniffType determines the cartridge format of data by looking at its
+	content. It recognizes MDR files by their size being a multiple of the
+	sector length plus a valid write protection byte, MDV files by their
+	size being a multiple of the sector length, Z80 snapshots by their
+	header, SNA snapshots by their size, TZX files by their signature, and
+	JSON cartridges by their leading brace. If no format could be
+	determined, the empty string is returned. TAP files cannot be detected.
+*/
+func SniffType(data []byte) string {
+
+	size := len(data)
+
+	switch {
+
+	case bytes.HasPrefix(data, tzxMagic):
+		return "tzx"
+
+	case size == SNALength:
+		return "sna"
+
+	case size >= MDVSectorLength && size%MDVSectorLength == 0 &&
+		data[12] == 0xff:
+		return "mdv"
+
+	case size > MDRSectorLength && size%MDRSectorLength == 1 &&
+		isWriteProtectFlag(data[size-1]):
+		return "mdr"
+
+	case isJSON(data): // before Z80, since version 1 detection is weak
+		return "json"
+
+	case isZ80(data):
+		return "z80"
+	}
+
+	return ""
+}
+
+// isJSON determines whether data looks like a JSON object
+func isJSON(data []byte) bool {
+	d := bytes.TrimSpace(data)
+	return bytes.HasPrefix(d, []byte("{")) && bytes.HasSuffix(d, []byte("}"))
+}
+
+// isZ80 determines whether data is a version 1, 2, or 3 Z80 snapshot. Version 1
+// headers have a non-zero program counter, and the memory dump following them
+// is either uncompressed with fixed length, or compressed with an end marker.
+// For versions 2 and 3, the program counter is zero, and followed by the length
+// of an additional header.
+func isZ80(data []byte) bool {
+
+	if len(data) <= 30 {
+		return false
+	}
+
+	if data[6] != 0 || data[7] != 0 { // version 1
+		if data[12]&0x20 == 0 && data[12] != 0xff { // not compressed
+			return len(data) == 30+48*1024
+		}
+		return bytes.HasSuffix(data, []byte{0x00, 0xed, 0xed, 0x00})
+	}
+
+	if len(data) < 32 {
+		return false
+	}
+
+	switch int(data[30]) | int(data[31])<<8 {
+	case 23, 54, 55: // versions 2 and 3
+		return len(data) > 32+int(data[30])
+	}
+
+	return false
+}
+
+// readCloser combines a reader with the closer of an underlying reader
+type readCloser struct {
+	io.Reader
+	closer io.Closer
+}
+
+//
+func (r *readCloser) Close() error {
+	return r.closer.Close()
+}
@@ -0,0 +1,178 @@
+/*
+   OqtaDrive - Sinclair Microdrive emulator
+   Copyright (c) 2022, Alexander Vollschwitz
+
+   This file is part of OqtaDrive.
+
+   OqtaDrive is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   OqtaDrive is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with OqtaDrive. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package format
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/xelalexv/oqtadrive/pkg/microdrive/base"
+	"github.com/xelalexv/oqtadrive/pkg/microdrive/client"
+	"github.com/xelalexv/oqtadrive/pkg/microdrive/if1"
+	"github.com/xelalexv/oqtadrive/pkg/util"
+)
+
+// TAP is a format for loading Spectrum tape images. Each pair of header and
+// data blocks is converted into a file on an IF1 cartridge. When writing, the
+// files on a cartridge are exported to a tape image.
+type TAP struct{}
+
+//
+func NewTAP() *TAP {
+	return &TAP{}
+}
+
+//
+func (t *TAP) Read(in io.Reader, strict, repair bool,
+	p util.Params) (*base.Cartridge, error) {
+
+	var blocks []tapeBlock
+
+	for {
+		var l [2]byte
+		if _, err := io.ReadFull(in, l[:]); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, fmt.Errorf("error reading TAP file: %v", err)
+		}
+
+		b := make(tapeBlock, int(l[0])|int(l[1])<<8)
+		if _, err := io.ReadFull(in, b); err != nil {
+			return nil, fmt.Errorf("error reading TAP block %d: %v",
+				len(blocks), err)
+		}
+		blocks = append(blocks, b)
+	}
+
+	files, err := tapeFilesFromBlocks(blocks, strict)
+	if err != nil {
+		return nil, err
+	}
+
+	name, _ := p.GetString("name")
+	loader, _ := p.GetBool("loader")
+
+	cart, err := tapeToCartridge(files, name, loader)
+	if err != nil {
+		return nil, err
+	}
+
+	if repair {
+		RepairOrder(cart)
+	}
+
+	return cart, nil
+}
+
+// Write exports all files on an IF1 cartridge as a TAP file, each file as a
+// pair of header and data blocks. Note that this is not a lossless conversion,
+// the result can be read back as a cartridge with the same files, but not the
+// same cartridge. PRINT files are skipped, since they have no file header from
+// which to create a tape header.
+func (t *TAP) Write(cart *base.Cartridge, out io.Writer, p util.Params) error {
+
+	if cart.Client() != client.IF1 {
+		return fmt.Errorf("TAP export only supported for IF1 cartridges")
+	}
+
+	_, files, err := cart.FS().Ls()
+	if err != nil {
+		return err
+	}
+
+	for _, fi := range files {
+
+		name := strings.TrimSpace(fi.Name())
+
+		f, err := cart.FS().Open(name)
+		if err != nil {
+			return fmt.Errorf("error opening file '%s': %v", name, err)
+		}
+
+		first, err := f.FirstRecord()
+		if err != nil {
+			return fmt.Errorf("error reading file '%s': %v", name, err)
+		}
+		if len(if1.FileParams(first)) == 0 {
+			log.Warnf("skipping PRINT file '%s', cannot be exported to tape", name)
+			continue
+		}
+
+		h, err := f.Header()
+		if err != nil {
+			return fmt.Errorf("error reading header of file '%s': %v", name, err)
+		}
+
+		data, err := f.Bytes()
+		if err != nil {
+			return fmt.Errorf("error reading file '%s': %v", name, err)
+		}
+
+		if len(data) > 0xffff {
+			return fmt.Errorf("file '%s' too large for tape", name)
+		}
+
+		header := make([]byte, 17)
+		header[0] = h[0]
+		copy(header[1:11], fmt.Sprintf("%-10.10s", name))
+		header[11] = byte(len(data))
+		header[12] = byte(len(data) >> 8)
+
+		if h[0] == 0 { // program: auto-run line & program length
+			copy(header[13:15], h[7:9])
+			copy(header[15:17], h[5:7])
+		} else { // start address
+			copy(header[13:15], h[3:5])
+			header[16] = 0x80
+		}
+
+		if err := writeTapeBlock(out, 0x00, header); err != nil {
+			return err
+		}
+		if err := writeTapeBlock(out, 0xff, data); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// writeTapeBlock writes a TAP block, i.e. length, flag, data, and checksum
+func writeTapeBlock(out io.Writer, flag byte, data []byte) error {
+
+	l := len(data) + 2
+	b := make([]byte, 0, l+2)
+	b = append(b, byte(l), byte(l>>8), flag)
+	b = append(b, data...)
+
+	check := flag
+	for _, d := range data {
+		check ^= d
+	}
+	b = append(b, check)
+
+	_, err := out.Write(b)
+	return err
+}
@@ -0,0 +1,272 @@
+/*
+   OqtaDrive - Sinclair Microdrive emulator
+   Copyright (c) 2022, Alexander Vollschwitz
+
+   This file is part of OqtaDrive.
+
+   OqtaDrive is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   OqtaDrive is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with OqtaDrive. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package format
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/xelalexv/oqtadrive/pkg/microdrive/base"
+	"github.com/xelalexv/oqtadrive/pkg/microdrive/if1"
+	"github.com/xelalexv/oqtadrive/pkg/util"
+)
+
+// tapeBlock is a data block as stored on tape, i.e. flag byte, data, and
+// checksum byte
+type tapeBlock []byte
+
+//
+func (b tapeBlock) flag() byte {
+	if len(b) > 0 {
+		return b[0]
+	}
+	return 0xff
+}
+
+//
+func (b tapeBlock) data() []byte {
+	if len(b) < 2 {
+		return nil
+	}
+	return b[1 : len(b)-1]
+}
+
+//
+func (b tapeBlock) isHeader() bool {
+	return len(b) == 19 && b[0] == 0x00
+}
+
+// isValid checks the block's parity, i.e. all bytes XOR-ed including checksum
+// yield 0
+func (b tapeBlock) isValid() bool {
+	var p byte
+	for _, v := range b {
+		p ^= v
+	}
+	return len(b) > 1 && p == 0
+}
+
+// tapeFile is a Spectrum file as assembled from a header and data block pair
+type tapeFile struct {
+	typ    byte
+	name   string
+	param1 int
+	param2 int
+	data   []byte
+}
+
+//
+func (f *tapeFile) isProgram() bool {
+	return f.typ == 0
+}
+
+// params returns the file header settings for creating this file on an IF1
+// cartridge
+func (f *tapeFile) params() util.Params {
+
+	p := util.Params{"fileType": int(f.typ)}
+
+	switch f.typ {
+	case 0:
+		p["programLength"] = f.param2
+		if f.param1 < 0x8000 {
+			p["lineNumber"] = f.param1
+		}
+	default:
+		p["startAddress"] = f.param1
+	}
+
+	return p
+}
+
+// tapeFilesFromBlocks pairs up header and data blocks. Blocks that cannot be
+// converted cause an error when strict is set, and are skipped otherwise.
+func tapeFilesFromBlocks(blocks []tapeBlock, strict bool) ([]*tapeFile, error) {
+
+	var ret []*tapeFile
+
+	report := func(msg string, args ...interface{}) error {
+		if strict {
+			return fmt.Errorf(msg, args...)
+		}
+		log.Warnf(msg, args...)
+		return nil
+	}
+
+	for ix := 0; ix < len(blocks); ix++ {
+
+		b := blocks[ix]
+
+		if !b.isValid() {
+			if err := report("block %d has invalid checksum", ix); err != nil {
+				return nil, err
+			}
+		}
+
+		if !b.isHeader() {
+			if err := report(
+				"headerless block %d with flag %02X cannot be converted",
+				ix, b.flag()); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if ix+1 == len(blocks) || blocks[ix+1].isHeader() {
+			if err := report("header block %d without data", ix); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		h := b.data()
+		ix++
+		d := blocks[ix]
+
+		if !d.isValid() {
+			if err := report("block %d has invalid checksum", ix); err != nil {
+				return nil, err
+			}
+		}
+
+		f := &tapeFile{
+			typ:    h[0],
+			name:   tapeName(h[1:11]),
+			param1: int(h[13]) | int(h[14])<<8,
+			param2: int(h[15]) | int(h[16])<<8,
+			data:   d.data(),
+		}
+
+		if l := int(h[11]) | int(h[12])<<8; l < len(f.data) {
+			f.data = f.data[:l]
+		} else if l > len(f.data) {
+			if err := report("data block %d is too short, want %d, got %d",
+				ix, l, len(f.data)); err != nil {
+				return nil, err
+			}
+		}
+
+		log.WithFields(log.Fields{
+			"name": f.name, "type": f.typ, "length": len(f.data),
+		}).Debug("tape file")
+
+		ret = append(ret, f)
+	}
+
+	return ret, nil
+}
+
+// tapeName turns a file name from a tape header into a name that can be used
+// on a cartridge
+func tapeName(n []byte) string {
+	var b strings.Builder
+	for _, c := range n {
+		if c < 0x20 || c > 0x7e {
+			c = '_'
+		}
+		b.WriteByte(c)
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// tapeToCartridge creates an IF1 cartridge with given name containing the
+// provided tape files. If loader is set, the first BASIC program is stored as
+// run, with LOAD "" statements changed to load from microdrive 1.
+func tapeToCartridge(files []*tapeFile, name string,
+	loader bool) (*base.Cartridge, error) {
+
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no convertible files on tape")
+	}
+
+	if name == "" {
+		name = files[0].name
+	}
+	if name == "" {
+		name = "TAPE"
+	}
+
+	cart, err := if1.NewFormattedCartridge(
+		fmt.Sprintf("%.10s", name), if1.SectorCount)
+	if err != nil {
+		return nil, err
+	}
+
+	names := uniqueNames(files, loader)
+	fs := cart.FS()
+
+	for ix, f := range files {
+
+		data := f.data
+		params := f.params()
+		fName := names[ix]
+
+		if loader && f.isProgram() {
+			loader = false
+			fName = "run"
+			data, params = rewriteLoader(f, files[ix+1:], names[ix+1:])
+		}
+
+		if err := fs.Create(fName, data, params); err != nil {
+			return nil, fmt.Errorf("error storing file '%s': %v", fName, err)
+		}
+	}
+
+	cart.SetModified(false)
+	cart.SeekToStart()
+	cart.RewindAccessIx(true)
+
+	return cart, nil
+}
+
+// uniqueNames returns names for the given files that are unique and usable on
+// a cartridge; if loader is set, run is reserved for the loader
+func uniqueNames(files []*tapeFile, loader bool) []string {
+
+	ret := make([]string, len(files))
+	used := map[string]bool{"run": loader}
+
+	for ix, f := range files {
+
+		n := f.name
+		if n == "" {
+			n = "file"
+		}
+
+		cand := n
+		for i := 1; used[cand]; i++ {
+			suffix := strconv.Itoa(i)
+			base := n
+			if l := 10 - len(suffix); len(base) > l {
+				base = base[:l]
+			}
+			cand = strings.TrimSpace(base) + suffix
+		}
+
+		used[cand] = true
+		ret[ix] = cand
+	}
+
+	return ret
+}
@@ -0,0 +1,161 @@
+/*
+   OqtaDrive - Sinclair Microdrive emulator
+   Copyright (c) 2022, Alexander Vollschwitz
+
+   This file is part of OqtaDrive.
+
+   OqtaDrive is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   OqtaDrive is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with OqtaDrive. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package format
+
+import (
+	log "github.com/sirupsen/logrus"
+
+	"github.com/xelalexv/oqtadrive/pkg/util"
+)
+
+// BASIC tokens & markers relevant for loader rewriting
+const (
+	tokenREM     = 0xea
+	tokenLOAD    = 0xef
+	numberMarker = 0x0e
+)
+
+// rewriteLoader rewrites the LOAD statements in BASIC program f such that they
+// load from microdrive 1. Files following the program on tape are given in
+// files, their names on the cartridge in names. Each LOAD "" is pointed at the
+// next of these files, in order of appearance in the program. A LOAD with file
+// name is pointed at the next file with that name. Returned are the changed
+// program data and file header settings.
+func rewriteLoader(f *tapeFile, files []*tapeFile,
+	names []string) ([]byte, util.Params) {
+
+	next := 0
+
+	target := func(name string) (string, bool) {
+		j := next
+		if name != "" {
+			for k := next; k < len(files); k++ {
+				if files[k].name == name {
+					j = k
+					break
+				}
+			}
+		}
+		if j >= len(names) {
+			return "", false
+		}
+		next = j + 1
+		return names[j], true
+	}
+
+	progLength := f.param2
+	if progLength > len(f.data) {
+		progLength = len(f.data)
+	}
+	prog := f.data[:progLength]
+
+	var out []byte
+	pos := 0
+
+	for pos+4 <= len(prog) {
+
+		l := int(prog[pos+2]) | int(prog[pos+3])<<8
+		end := pos + 4 + l
+		if end > len(prog) {
+			break
+		}
+
+		line := rewriteLine(prog[pos+4:end], target)
+		out = append(out, prog[pos], prog[pos+1],
+			byte(len(line)), byte(len(line)>>8))
+		out = append(out, line...)
+		pos = end
+	}
+
+	out = append(out, prog[pos:]...) // anything left over, e.g. broken line
+	log.WithField("changes", next).Debug("rewrote loader")
+
+	params := f.params()
+	params["programLength"] = len(out)
+	if _, ok := params.GetInt("lineNumber"); !ok {
+		params["lineNumber"] = 0
+	}
+
+	return append(out, f.data[progLength:]...), params
+}
+
+// rewriteLine changes each LOAD "{name}" in body of a BASIC line to
+// LOAD *"m";1;"{target}", where target is determined by the target function.
+func rewriteLine(body []byte, target func(string) (string, bool)) []byte {
+
+	var out []byte
+	inString := false
+
+	for i := 0; i < len(body); i++ {
+
+		c := body[i]
+
+		switch {
+
+		case inString:
+			inString = c != '"'
+
+		case c == '"':
+			inString = true
+
+		case c == numberMarker && i+5 < len(body):
+			out = append(out, body[i:i+6]...)
+			i += 5
+			continue
+
+		case c == tokenREM:
+			return append(out, body[i:]...)
+
+		case c == tokenLOAD:
+			j := i + 1
+			for j < len(body) && body[j] == ' ' {
+				j++
+			}
+			if j < len(body) && body[j] == '"' {
+				k := j + 1
+				for k < len(body) && body[k] != '"' {
+					k++
+				}
+				if k < len(body) {
+					if name, ok := target(string(body[j+1 : k])); ok {
+						out = append(out, tokenLOAD)
+						out = append(out, microdriveLoad(name)...)
+						i = k
+						continue
+					}
+				}
+			}
+		}
+
+		out = append(out, c)
+	}
+
+	return out
+}
+
+// microdriveLoad returns the part following LOAD for loading file name from
+// microdrive 1, i.e. *"m";1;"{name}"
+func microdriveLoad(name string) []byte {
+	ret := []byte{'*', '"', 'm', '"', ';', '1', numberMarker, 0, 0, 1, 0, 0, ';',
+		'"'}
+	ret = append(ret, name...)
+	return append(ret, '"')
+}
@@ -0,0 +1,316 @@
+/*
+   OqtaDrive - Sinclair Microdrive emulator
+   Copyright (c) 2022, Alexander Vollschwitz
+
+   This file is part of OqtaDrive.
+
+   OqtaDrive is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   OqtaDrive is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with OqtaDrive. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package format
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/xelalexv/oqtadrive/pkg/microdrive/base"
+	"github.com/xelalexv/oqtadrive/pkg/util"
+)
+
+//
+var tzxSignature = []byte("ZXTape!\x1a")
+
+// lengths of fixed size TZX blocks that do not carry data we can use
+var tzxFixedBlocks = map[byte]int{
+	0x12: 4, // pure tone
+	0x20: 2, // pause or stop the tape
+	0x22: 0, // group end
+	0x23: 2, // jump to block
+	0x24: 2, // loop start
+	0x25: 0, // loop end
+	0x27: 0, // return from sequence
+	0x2a: 4, // stop the tape if in 48K mode
+	0x34: 8, // emulation info
+	0x5a: 9, // glue block
+}
+
+// TZX is a format for loading Spectrum tape images in TZX format. Standard
+// speed data blocks, as well as turbo speed and pure data blocks holding
+// regular header or data blocks, are converted into files on an IF1 cartridge,
+// in the same way as for TAP files. Blocks with other kinds of data, such as
+// direct recordings, cannot be converted. Writing exports the files on a
+// cartridge as standard speed data blocks.
+type TZX struct{}
+
+//
+func NewTZX() *TZX {
+	return &TZX{}
+}
+
+//
+func (t *TZX) Read(in io.Reader, strict, repair bool,
+	p util.Params) (*base.Cartridge, error) {
+
+	sig := make([]byte, len(tzxSignature)+2)
+	if _, err := io.ReadFull(in, sig); err != nil {
+		return nil, fmt.Errorf("error reading TZX header: %v", err)
+	}
+	if !bytes.Equal(sig[:len(tzxSignature)], tzxSignature) {
+		return nil, fmt.Errorf("not a TZX file")
+	}
+	log.Debugf("TZX version %d.%02d", sig[8], sig[9])
+
+	var blocks []tapeBlock
+
+	for ix := 0; ; ix++ {
+
+		id, err := readBytes(in, 1)
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, fmt.Errorf("error reading TZX block %d: %v", ix, err)
+		}
+
+		b, err := readTZXBlock(in, id[0])
+		if err != nil {
+			msg := fmt.Sprintf("TZX block %d with ID %02X: %v", ix, id[0], err)
+			if b == nil || strict {
+				return nil, fmt.Errorf(msg)
+			}
+			log.Warn(msg)
+			continue
+		}
+
+		if b != nil {
+			blocks = append(blocks, b)
+		}
+	}
+
+	files, err := tapeFilesFromBlocks(blocks, strict)
+	if err != nil {
+		return nil, err
+	}
+
+	name, _ := p.GetString("name")
+	loader, _ := p.GetBool("loader")
+
+	cart, err := tapeToCartridge(files, name, loader)
+	if err != nil {
+		return nil, err
+	}
+
+	if repair {
+		RepairOrder(cart)
+	}
+
+	return cart, nil
+}
+
+// readTZXBlock reads the TZX block with given ID. For blocks holding usable
+// data, the tape block is returned, nil otherwise. If a block cannot be
+// converted but was skipped successfully, the returned error is accompanied by
+// an empty, non-nil block. An error with a nil block means the TZX file cannot
+// be read any further.
+func readTZXBlock(in io.Reader, id byte) (tapeBlock, error) {
+
+	var length int
+	var usedBits byte = 8
+
+	switch id {
+
+	case 0x10: // standard speed data
+		h, err := readBytes(in, 4)
+		if err != nil {
+			return nil, err
+		}
+		length = getInt(h, 2, 2)
+
+	case 0x11: // turbo speed data
+		h, err := readBytes(in, 18)
+		if err != nil {
+			return nil, err
+		}
+		usedBits = h[12]
+		length = getInt(h, 15, 3)
+
+	case 0x14: // pure data
+		h, err := readBytes(in, 10)
+		if err != nil {
+			return nil, err
+		}
+		usedBits = h[4]
+		length = getInt(h, 7, 3)
+
+	case 0x15: // direct recording
+		return skipTZXBlock(in, 5, 3, "direct recording")
+
+	case 0x18: // CSW recording
+		return skipTZXBlock(in, 0, 4, "CSW recording")
+
+	case 0x19: // generalized data
+		return skipTZXBlock(in, 0, 4, "generalized data")
+
+	case 0x13: // pulse sequence
+		n, err := readBytes(in, 1)
+		if err != nil {
+			return nil, err
+		}
+		return nil, skip(in, 2*int(n[0]))
+
+	case 0x21, 0x30: // group start, text description
+		return nil, skipVariable(in, 1)
+
+	case 0x26: // call sequence
+		n, err := readBytes(in, 2)
+		if err != nil {
+			return nil, err
+		}
+		return nil, skip(in, 2*getInt(n, 0, 2))
+
+	case 0x28, 0x32: // select block, archive info
+		return nil, skipVariable(in, 2)
+
+	case 0x2b: // set signal level
+		return nil, skipVariable(in, 4)
+
+	case 0x31: // message
+		if _, err := readBytes(in, 1); err != nil {
+			return nil, err
+		}
+		return nil, skipVariable(in, 1)
+
+	case 0x33: // hardware type
+		n, err := readBytes(in, 1)
+		if err != nil {
+			return nil, err
+		}
+		return nil, skip(in, 3*int(n[0]))
+
+	case 0x35: // custom info
+		if _, err := readBytes(in, 16); err != nil {
+			return nil, err
+		}
+		return nil, skipVariable(in, 4)
+
+	default:
+		if l, ok := tzxFixedBlocks[id]; ok {
+			return nil, skip(in, l)
+		}
+		// as per spec, all unknown blocks start with a 4 byte length
+		if err := skipVariable(in, 4); err != nil {
+			return nil, err
+		}
+		return tapeBlock{}, fmt.Errorf("unsupported block skipped")
+	}
+
+	data, err := readBytes(in, length)
+	if err != nil {
+		return nil, err
+	}
+
+	b := tapeBlock(data)
+	if usedBits != 8 || !b.isValid() {
+		return tapeBlock{}, fmt.Errorf("block does not hold regular tape data")
+	}
+
+	return b, nil
+}
+
+// skipTZXBlock skips a block that holds data that cannot be converted; fixed
+// denotes the number of bytes before the length field, size the length of that
+// field
+func skipTZXBlock(in io.Reader, fixed, size int, kind string) (tapeBlock, error) {
+	if err := skip(in, fixed); err != nil {
+		return nil, err
+	}
+	if err := skipVariable(in, size); err != nil {
+		return nil, err
+	}
+	return tapeBlock{}, fmt.Errorf("%s cannot be converted", kind)
+}
+
+// skipVariable skips a variable length section, preceded by a little endian
+// length field of given size
+func skipVariable(in io.Reader, size int) error {
+	l, err := readBytes(in, size)
+	if err != nil {
+		return err
+	}
+	return skip(in, getInt(l, 0, size))
+}
+
+//
+func skip(in io.Reader, n int) error {
+	_, err := io.CopyN(io.Discard, in, int64(n))
+	return err
+}
+
+//
+func readBytes(in io.Reader, n int) ([]byte, error) {
+	b := make([]byte, n)
+	if _, err := io.ReadFull(in, b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// getInt gets a little endian integer of given size from b at offset
+func getInt(b []byte, offset, size int) int {
+	ret := 0
+	for ix := size - 1; ix >= 0; ix-- {
+		ret = ret<<8 | int(b[offset+ix])
+	}
+	return ret
+}
+
+// Write exports the files of an IF1 cartridge as a TZX file. The tape blocks
+// are the same as written by TAP, each placed into a standard speed data
+// block. As for TAP, this is not a lossless conversion.
+func (t *TZX) Write(cart *base.Cartridge, out io.Writer, p util.Params) error {
+
+	var tap bytes.Buffer
+	if err := NewTAP().Write(cart, &tap, p); err != nil {
+		return err
+	}
+
+	if _, err := out.Write(tzxSignature); err != nil {
+		return err
+	}
+	if _, err := out.Write([]byte{1, 20}); err != nil { // version 1.20
+		return err
+	}
+
+	blocks := tap.Bytes()
+
+	for len(blocks) >= 2 {
+		length := getInt(blocks, 0, 2)
+		if len(blocks) < length+2 {
+			return fmt.Errorf("incomplete tape block")
+		}
+		// ID, 1000ms pause after block, length; followed by block data
+		if _, err := out.Write([]byte{0x10, 0xe8, 0x03}); err != nil {
+			return err
+		}
+		if _, err := out.Write(blocks[:length+2]); err != nil {
+			return err
+		}
+		blocks = blocks[length+2:]
+	}
+
+	return nil
+}
@@ -76,3 +76,16 @@ func (z *Z80) Read(in io.Reader, strict, repair bool,
 func (z *Z80) Write(cart *base.Cartridge, out io.Writer, p util.Params) error {
 	return NewMDR().Write(cart, out, p)
 }
+
+// Screen reads a Z80 or SNA snapshot, and returns its screen memory
+func (z *Z80) Screen(in io.Reader) (screen []byte, err error) {
+
+	defer func() {
+		if e := recover(); e != nil {
+			screen = nil
+			err = fmt.Errorf("unrecoverable error during snapshot reading: %v", e)
+		}
+	}()
+
+	return z80.GetScreen(in, z.sna)
+}
@@ -249,6 +249,10 @@ func (s *snapshot) unpack(in io.Reader) error {
 		}
 	}
 
+	// keep a copy of the screen, since the launcher may use screen memory
+	s.screen = make([]byte, screenSize)
+	copy(s.screen, s.main)
+
 	var hwm string
 	if hwm, err = validateHardwareMode(
 		s.launcher.hardwareMode(), s.version); err != nil {
@@ -44,6 +44,7 @@ type snapshot struct {
 	launcher launcher
 	main     []byte
 	bank     []int
+	screen   []byte
 	//
 	name string
 	cart *base.Cartridge
@@ -57,6 +58,26 @@ func (s *snapshot) setName(n string) {
 	s.name = fmt.Sprintf("%.10s", fmt.Sprintf("%-10s", n))
 }
 
+// size of screen memory, bitmap plus attributes
+const screenSize = 6912
+
+// GetScreen reads a Z80 or SNA snapshot and returns the content of its screen
+// memory. For 128k snapshots, this is the normal screen in page 5.
+func GetScreen(in io.Reader, sna bool) ([]byte, error) {
+
+	l, err := newLauncher("")
+	if err != nil {
+		return nil, err
+	}
+
+	snap := &snapshot{launcher: l, sna: sna}
+	if err := snap.unpack(in); err != nil {
+		return nil, fmt.Errorf("error unpacking snapshot: %v", err)
+	}
+
+	return snap.screen, nil
+}
+
 // reads Z80 snapshot and converts it into a cartridge on the fly
 //
 func LoadZ80(in io.Reader, name, launcher string, sna bool) (*base.Cartridge, error) {
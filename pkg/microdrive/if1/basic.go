@@ -0,0 +1,347 @@
+/*
+   OqtaDrive - Sinclair Microdrive emulator
+   Copyright (c) 2022, Alexander Vollschwitz
+
+   This file is part of OqtaDrive.
+
+   OqtaDrive is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   OqtaDrive is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with OqtaDrive. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package if1
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/xelalexv/oqtadrive/pkg/microdrive/base"
+)
+
+// markers in a BASIC program
+const (
+	markerNumber = 0x0e
+	markerEnter  = 0x0d
+	markerEnd    = 0x80 // end of variables area
+)
+
+// embedded colour and position control codes, with number of parameter bytes
+var controlCodes = map[byte]struct {
+	name   string
+	params int
+}{
+	0x10: {"INK", 1},
+	0x11: {"PAPER", 1},
+	0x12: {"FLASH", 1},
+	0x13: {"BRIGHT", 1},
+	0x14: {"INVERSE", 1},
+	0x15: {"OVER", 1},
+	0x16: {"AT", 2},
+	0x17: {"TAB", 2},
+}
+
+// Listing returns the detokenized listing of a BASIC program file, including
+// its variables, if any.
+func Listing(f *base.File) (string, error) {
+
+	h, err := f.Header()
+	if err != nil {
+		return "", err
+	}
+
+	if h[0] != 0 {
+		return "", fmt.Errorf("not a BASIC program")
+	}
+
+	data, err := f.Bytes()
+	if err != nil {
+		return "", err
+	}
+
+	return Detokenize(data, int(h[5])|int(h[6])<<8)
+}
+
+// Detokenize renders a BASIC program as UTF-8 text. data is the complete file
+// content, progLength the length of the program part. Anything beyond that is
+// the variables area, and is listed after the program. Hidden number forms are
+// skipped, embedded control codes are rendered as {INK 2}, {AT 1,3}, etc.
+func Detokenize(data []byte, progLength int) (string, error) {
+
+	if progLength > len(data) {
+		return "", fmt.Errorf("program length %d exceeds file length %d",
+			progLength, len(data))
+	}
+
+	var b strings.Builder
+	prog := data[:progLength]
+
+	for pos := 0; pos < len(prog); {
+
+		if pos+4 > len(prog) {
+			return b.String(), fmt.Errorf("incomplete line at offset %d", pos)
+		}
+
+		number := int(prog[pos])<<8 | int(prog[pos+1])
+		end := pos + 4 + (int(prog[pos+2]) | int(prog[pos+3])<<8)
+		if end > len(prog) {
+			return b.String(), fmt.Errorf("line %d too long", number)
+		}
+
+		fmt.Fprintf(&b, "%d ", number)
+		detokenizeLine(&b, prog[pos+4:end])
+		b.WriteByte('\n')
+		pos = end
+	}
+
+	if vars := data[progLength:]; len(vars) > 0 && vars[0] != markerEnd {
+		b.WriteString("\n; variables\n")
+		if err := listVariables(&b, vars); err != nil {
+			return b.String(), err
+		}
+	}
+
+	return b.String(), nil
+}
+
+//
+func detokenizeLine(b *strings.Builder, line []byte) {
+
+	inString := false
+	lastSpace := true
+
+	for i := 0; i < len(line); i++ {
+
+		c := line[i]
+
+		if c == markerEnter && i == len(line)-1 {
+			break
+		}
+
+		if c == '"' {
+			inString = !inString
+		}
+
+		if !inString && c == markerNumber {
+			i += 5
+			continue
+		}
+
+		if cc, ok := controlCodes[c]; ok && i+cc.params < len(line) {
+			p := line[i+1 : i+1+cc.params]
+			switch c {
+			case 0x16:
+				fmt.Fprintf(b, "{AT %d,%d}", p[0], p[1])
+			case 0x17:
+				fmt.Fprintf(b, "{TAB %d}", int(p[0])|int(p[1])<<8)
+			default:
+				fmt.Fprintf(b, "{%s %d}", cc.name, p[0])
+			}
+			i += cc.params
+			lastSpace = false
+			continue
+		}
+
+		if kw := keyword(c); kw != "" {
+			// same spacing rules as in ROM: statements and operators from OR
+			// onwards get a leading space, except for comparisons; all but
+			// RND, INKEY$, and PI get a trailing space, unless ending in a
+			// symbol other than $
+			if leadingSpace(c) && !lastSpace {
+				b.WriteByte(' ')
+			}
+			b.WriteString(kw)
+			lastSpace = false
+			if last := kw[len(kw)-1]; c > 0xa7 && (last == '$' || last >= 'A') {
+				b.WriteByte(' ')
+				lastSpace = true
+			}
+			continue
+		}
+
+		if r, ok := spectrumToUTF8(c); ok {
+			b.WriteRune(r)
+		} else {
+			fmt.Fprintf(b, "{%02X}", c)
+		}
+		lastSpace = c == ' '
+	}
+}
+
+// leadingSpace determines whether keyword token t is preceded by a space when
+// listed
+func leadingSpace(t byte) bool {
+	return t >= 0xc5 && (t < 0xc7 || t > 0xc9)
+}
+
+// listVariables lists the variables contained in vars, one per line
+func listVariables(b *strings.Builder, vars []byte) error {
+
+	pos := 0
+
+	need := func(n int) error {
+		if pos+n > len(vars) {
+			return fmt.Errorf("variables area truncated at offset %d", pos)
+		}
+		return nil
+	}
+
+	for pos < len(vars) && vars[pos] != markerEnd {
+
+		t := vars[pos]
+		letter := string(rune(t&0x1f | 0x60))
+		pos++
+
+		switch t & 0xe0 {
+
+		case 0x60: // number with single letter name
+			if err := need(5); err != nil {
+				return err
+			}
+			fmt.Fprintf(b, "%s = %s\n", letter, formatNumber(vars[pos:pos+5]))
+			pos += 5
+
+		case 0xa0: // number with long name
+			name := letter
+			for {
+				if err := need(1); err != nil {
+					return err
+				}
+				c := vars[pos]
+				pos++
+				name += string(rune(c & 0x7f))
+				if c&0x80 != 0 {
+					break
+				}
+			}
+			if err := need(5); err != nil {
+				return err
+			}
+			fmt.Fprintf(b, "%s = %s\n", name, formatNumber(vars[pos:pos+5]))
+			pos += 5
+
+		case 0xe0: // FOR loop control variable
+			if err := need(18); err != nil {
+				return err
+			}
+			v := vars[pos : pos+18]
+			fmt.Fprintf(b, "%s = %s (FOR TO %s STEP %s, line %d:%d)\n", letter,
+				formatNumber(v[0:5]), formatNumber(v[5:10]), formatNumber(v[10:15]),
+				int(v[15])|int(v[16])<<8, v[17])
+			pos += 18
+
+		case 0x40: // string
+			if err := need(2); err != nil {
+				return err
+			}
+			l := int(vars[pos]) | int(vars[pos+1])<<8
+			pos += 2
+			if err := need(l); err != nil {
+				return err
+			}
+			fmt.Fprintf(b, "%s$ = \"%s\"\n", letter, toUTF8(vars[pos:pos+l]))
+			pos += l
+
+		case 0x80, 0xc0: // numeric or character array
+			if err := need(3); err != nil {
+				return err
+			}
+			l := int(vars[pos]) | int(vars[pos+1])<<8
+			pos += 2
+			if err := need(l); err != nil {
+				return err
+			}
+			arr := vars[pos : pos+l]
+			pos += l
+			if err := listArray(b, letter, t&0xe0 == 0xc0, arr); err != nil {
+				return err
+			}
+
+		default:
+			return fmt.Errorf("invalid variable type %02X at offset %d", t, pos-1)
+		}
+	}
+
+	return nil
+}
+
+// listArray lists an array with given name; arr starts with the number of
+// dimensions, followed by the dimension sizes, and the elements
+func listArray(b *strings.Builder, name string, chars bool, arr []byte) error {
+
+	if len(arr) < 1 || len(arr) < 1+2*int(arr[0]) {
+		return fmt.Errorf("array %s truncated", name)
+	}
+
+	dims := make([]string, arr[0])
+	for ix := range dims {
+		dims[ix] = strconv.Itoa(int(arr[1+2*ix]) | int(arr[2+2*ix])<<8)
+	}
+	elements := arr[1+2*len(dims):]
+
+	if chars {
+		fmt.Fprintf(b, "%s$(%s) = \"%s\"\n",
+			name, strings.Join(dims, ","), toUTF8(elements))
+		return nil
+	}
+
+	var values []string
+	for ix := 0; ix+5 <= len(elements); ix += 5 {
+		values = append(values, formatNumber(elements[ix:ix+5]))
+	}
+	fmt.Fprintf(b, "%s(%s) = %s\n",
+		name, strings.Join(dims, ","), strings.Join(values, ", "))
+
+	return nil
+}
+
+//
+func toUTF8(s []byte) string {
+	var b strings.Builder
+	for _, c := range s {
+		if r, ok := spectrumToUTF8(c); ok {
+			b.WriteRune(r)
+		} else if kw := keyword(c); kw != "" {
+			b.WriteString(kw)
+		} else {
+			fmt.Fprintf(&b, "{%02X}", c)
+		}
+	}
+	return b.String()
+}
+
+// decodeNumber decodes a number in Spectrum 5 byte format, either small integer
+// or floating point
+func decodeNumber(n []byte) float64 {
+
+	if n[0] == 0 { // small integer
+		v := int(n[2]) | int(n[3])<<8
+		if n[1] == 0xff {
+			v -= 0x10000
+		}
+		return float64(v)
+	}
+
+	m := uint32(n[1]|0x80)<<24 | uint32(n[2])<<16 | uint32(n[3])<<8 |
+		uint32(n[4])
+	v := math.Ldexp(float64(m), int(n[0])-128-32)
+	if n[1]&0x80 != 0 {
+		v = -v
+	}
+	return v
+}
+
+//
+func formatNumber(n []byte) string {
+	return strconv.FormatFloat(decodeNumber(n), 'g', 9, 64)
+}
@@ -21,6 +21,8 @@
 package if1
 
 import (
+	"fmt"
+
 	"github.com/xelalexv/oqtadrive/pkg/microdrive/base"
 	"github.com/xelalexv/oqtadrive/pkg/microdrive/client"
 )
@@ -34,6 +36,47 @@ func NewCartridge() *base.Cartridge {
 	return cart
 }
 
+// NewFormattedCartridge creates a cartridge as left behind by FORMAT, with
+// given name and number of sectors. Sectors are numbered 1 through sectors, as
+// happens on a real tape shorter than the maximum sector count.
+func NewFormattedCartridge(name string, sectors int) (*base.Cartridge, error) {
+
+	if sectors < 1 || sectors > SectorCount {
+		return nil, fmt.Errorf(
+			"sector count needs to be between 1 and %d", SectorCount)
+	}
+
+	if len(name) > 10 {
+		return nil, fmt.Errorf("cartridge name must not exceed 10 characters")
+	}
+
+	cart := NewCartridge()
+
+	for ix := 0; ix < sectors; ix++ {
+
+		hd, err := newHeader(name, ix+1)
+		if err != nil {
+			return nil, err
+		}
+
+		rec, err := newBlankRecord()
+		if err != nil {
+			return nil, err
+		}
+
+		sec, err := base.NewSector(hd, rec)
+		if err != nil {
+			return nil, err
+		}
+		cart.SetSectorAt(ix, sec)
+	}
+
+	cart.SetName(fmt.Sprintf("%-10s", name))
+	cart.SeekToStart()
+
+	return cart, nil
+}
+
 //
 type cartridge struct {
 	*base.Cartridge
@@ -122,6 +122,62 @@ var keywords = []string{
 //
 var keywordReplace = strings.NewReplacer(keywords...)
 
+// Unicode characters for Spectrum characters not in ASCII; block graphics
+// 0x80 through 0x8f map to block elements, 0x80 being a no-break space, user
+// defined graphics 0x90 (A) through 0xa4 (U) to circled letters
+var specialChars = map[byte]rune{
+	0x60: '£',
+	0x7f: '©',
+	0x80: '\u00a0',
+	0x81: '▝',
+	0x82: '▘',
+	0x83: '▀',
+	0x84: '▗',
+	0x85: '▐',
+	0x86: '▚',
+	0x87: '▜',
+	0x88: '▖',
+	0x89: '▞',
+	0x8a: '▌',
+	0x8b: '▛',
+	0x8c: '▄',
+	0x8d: '▟',
+	0x8e: '▙',
+	0x8f: '█',
+}
+
+// first & last user defined graphics character, and Unicode rune for first
+const udgFirst = 0x90
+const udgLast = 0xa4
+const udgRune = 'Ⓐ'
+
+// first keyword token
+const tokenFirst = 0xa5
+
+// keyword returns the keyword for token t, or an empty string if t is not a
+// token; spaces within keywords are plain spaces
+func keyword(t byte) string {
+	if t < tokenFirst {
+		return ""
+	}
+	return strings.ReplaceAll(keywords[2*int(t-tokenFirst)+1], "\u00a0", " ")
+}
+
+// spectrumToUTF8 returns the Unicode character for Spectrum character c; for
+// control codes and tokens, ok is false
+func spectrumToUTF8(c byte) (r rune, ok bool) {
+	if r, ok := specialChars[c]; ok {
+		return r, true
+	}
+	switch {
+	case '\x20' <= c && c < '\x7f':
+		return rune(c), true
+	case udgFirst <= c && c <= udgLast:
+		return udgRune + rune(c-udgFirst), true
+	}
+	return 0, false
+}
+
 //
 func translate(s string) string {
 	if strings.HasPrefix(s, "\x00") {
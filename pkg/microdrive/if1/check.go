@@ -0,0 +1,199 @@
+/*
+   OqtaDrive - Sinclair Microdrive emulator
+   Copyright (c) 2022, Alexander Vollschwitz
+
+   This file is part of OqtaDrive.
+
+   OqtaDrive is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   OqtaDrive is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with OqtaDrive. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package if1
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/xelalexv/oqtadrive/pkg/microdrive/base"
+)
+
+// fileRecord is a used record together with the number of its sector
+type fileRecord struct {
+	sector int
+	rec    *record
+}
+
+/*
+	Check checks the file system for these problems:
+
+		- sector headers with a cartridge name differing from the majority
+		- files with missing or duplicate record indices
+		- files with missing end record, or records beyond the end record
+		- record lengths that disagree with the file header
+
+	When repair is set, deviating cartridge names are corrected, and duplicate
+	records with identical content are erased.
+*/
+func (fs *fsys) Check(repair bool) (*base.CheckReport, error) {
+
+	if repair && fs.cart.IsWriteProtected() {
+		return nil, fmt.Errorf("cartridge is write protected")
+	}
+
+	report := base.NewCheckReport()
+
+	var rename func(h base.Header, name string) error
+	if repair {
+		rename = func(h base.Header, name string) error {
+			if hd, ok := h.(*header); ok {
+				return hd.setName(name)
+			}
+			return fmt.Errorf("not an IF1 header")
+		}
+	}
+	base.CheckHeaderNames(fs.cart, report, rename)
+
+	files := make(map[string][]*fileRecord)
+
+	for ix := 0; ix < fs.cart.SectorCount(); ix++ {
+		if s := fs.cart.GetSectorAt(ix); s != nil {
+			if r, ok := s.Record().(*record); ok && r != nil &&
+				r.Flags()&RecordFlagsUsed != 0 {
+				name := strings.TrimSpace(translate(r.Name()))
+				files[name] = append(files[name], &fileRecord{s.Index(), r})
+			}
+		}
+	}
+
+	var names []string
+	for n := range files {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+
+	for _, n := range names {
+		if err := checkFile(n, files[n], report, repair); err != nil {
+			return nil, err
+		}
+	}
+
+	if report.Repaired() > 0 {
+		fs.cart.SetModified(true)
+	}
+
+	return report, nil
+}
+
+//
+func checkFile(name string, recs []*fileRecord, report *base.CheckReport,
+	repair bool) error {
+
+	byIndex := make(map[int][]*fileRecord)
+	last := 0
+	for _, fr := range recs {
+		ix := fr.rec.Index()
+		byIndex[ix] = append(byIndex[ix], fr)
+		if ix > last {
+			last = ix
+		}
+	}
+
+	// duplicates; the one passing by the head first is considered original
+	for ix := 0; ix <= last; ix++ {
+
+		dups := byIndex[ix]
+		if len(dups) < 2 {
+			continue
+		}
+		sort.Slice(dups, func(i, j int) bool {
+			return dups[i].sector > dups[j].sector
+		})
+
+		for _, d := range dups[1:] {
+			repaired := false
+			if repair && sameRecordContent(dups[0].rec, d.rec) {
+				if err := d.rec.erase(); err != nil {
+					return fmt.Errorf("error erasing record: %v", err)
+				}
+				repaired = true
+			}
+			report.Add(base.ProblemDuplicateRecord, name, d.sector, repaired,
+				"record %d also present in sector %d", ix, dups[0].sector)
+		}
+	}
+
+	// missing records & end record
+	missing := false
+	end := -1
+	for ix := 0; ix <= last; ix++ {
+		if len(byIndex[ix]) == 0 {
+			missing = true
+			report.Add(base.ProblemMissingRecord, name, -1, false,
+				"record %d missing", ix)
+			continue
+		}
+		r := byIndex[ix][0]
+		if r.rec.Flags()&RecordFlagEOF != 0 {
+			if end == -1 {
+				end = ix
+			}
+		} else if r.rec.Length() != base.FileBlockLength {
+			report.Add(base.ProblemLengthMismatch, name, r.sector, false,
+				"record %d is not the last, but has length %d",
+				ix, r.rec.Length())
+		}
+	}
+
+	if end == -1 {
+		report.Add(base.ProblemMissingRecord, name, -1, false,
+			"no end record found")
+	} else if end < last {
+		for ix := end + 1; ix <= last; ix++ {
+			if len(byIndex[ix]) > 0 {
+				report.Add(base.ProblemExtraRecord, name, byIndex[ix][0].sector,
+					false, "record %d beyond end record %d", ix, end)
+			}
+		}
+	}
+
+	// total length vs. file header, only for complete data files
+	if missing || end != last {
+		return nil
+	}
+
+	first := byIndex[0][0].rec
+	if first.Flags()&RecordFlagData == 0 { // PRINT file, no header
+		return nil
+	}
+
+	total := 0
+	for ix := 0; ix <= last; ix++ {
+		total += byIndex[ix][0].rec.Length()
+	}
+
+	if want := first.block.GetInt("fileLength") + FileHeaderLength; total != want {
+		report.Add(base.ProblemLengthMismatch, name, -1, false,
+			"records hold %d bytes, file header states %d", total, want)
+	}
+
+	return nil
+}
+
+// sameRecordContent determines whether two records hold the same file block
+func sameRecordContent(a, b *record) bool {
+	return a.Flags() == b.Flags() && a.Length() == b.Length() &&
+		a.Length() <= len(a.Data()) &&
+		bytes.Equal(a.Data()[:a.Length()], b.Data()[:b.Length()])
+}
@@ -32,5 +32,10 @@ const FileHeaderLength = 9 // only in first record of each file
 //
 const RecordFlagsUsed = 0x06
 
+// record flag bits marking the last record of a file, and a data file, i.e. a
+// file that is not a PRINT file; only data files have a file header
+const RecordFlagEOF = 0x02
+const RecordFlagData = 0x04
+
 // sector numbers range from 1 through 254
 const SectorCount = 254
@@ -0,0 +1,192 @@
+/*
+   OqtaDrive - Sinclair Microdrive emulator
+   Copyright (c) 2022, Alexander Vollschwitz
+
+   This file is part of OqtaDrive.
+
+   OqtaDrive is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   OqtaDrive is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with OqtaDrive. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package if1
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/xelalexv/oqtadrive/pkg/microdrive/base"
+)
+
+// Interleave used when placing records of a file during defragmenting, i.e. the
+// distance in sectors between two consecutive records. This gives the ROM time
+// to process a record before the next one passes by the head. The same
+// interleave is used when converting snapshots, see z80/pack.go.
+const defragInterleave = 2
+
+/*
+	Defrag rewrites all files on the cartridge into contiguous sectors, in
+	access order, i.e. the order in which sectors pass by the head. Records of
+	a file are placed with an interleave of two sectors, and there is an
+	additional gap between files. Files are placed in the order in which they
+	are currently found on the cartridge. The returned report contains for each
+	file an estimate of the cartridge revolutions needed for loading it, before
+	and after defragmenting.
+*/
+func Defrag(cart *base.Cartridge) (*base.DefragReport, error) {
+
+	if cart.IsWriteProtected() {
+		return nil, fmt.Errorf("cartridge is write protected")
+	}
+
+	// slot indexes of all sectors holding a record, in access order
+	var slots []int
+	for ix := 0; ix < cart.SectorCount(); ix++ {
+		if s := cart.GetSectorAt(ix); s != nil && s.Record() != nil {
+			slots = append(slots, ix)
+		}
+	}
+	sort.Slice(slots, func(i, j int) bool {
+		return cart.GetSectorAt(slots[i]).Index() >
+			cart.GetSectorAt(slots[j]).Index()
+	})
+
+	if len(slots) == 0 {
+		return nil, fmt.Errorf("cartridge is not formatted")
+	}
+
+	// files in order of first appearance, each with its records and their
+	// current positions in access order
+	type defragFile struct {
+		name      string
+		records   []*record
+		positions []int
+	}
+
+	var files []*defragFile
+	lookup := make(map[string]*defragFile)
+
+	for pos, ix := range slots {
+		r, ok := cart.GetSectorAt(ix).Record().(*record)
+		if !ok || !r.isUsed() {
+			continue
+		}
+		name := strings.TrimSpace(translate(r.Name()))
+		f, ok := lookup[name]
+		if !ok {
+			f = &defragFile{name: name}
+			lookup[name] = f
+			files = append(files, f)
+		}
+		f.records = append(f.records, r)
+		f.positions = append(f.positions, pos)
+	}
+
+	report := &base.DefragReport{}
+
+	// new layout
+	placed := make([]*record, len(slots))
+	next := 0
+
+	for _, f := range files {
+
+		sort.Sort(byRecordIndex{f.records, f.positions})
+		rev := &base.FileRevolutions{
+			File:   f.name,
+			Before: revolutions(f.positions, len(slots)),
+		}
+
+		for ix, r := range f.records {
+			pos := next % len(slots)
+			for placed[pos] != nil {
+				pos = (pos + 1) % len(slots)
+			}
+			placed[pos] = r
+			if pos != f.positions[ix] {
+				report.Moved++
+			}
+			f.positions[ix] = pos
+			next = pos + defragInterleave
+		}
+		next += defragInterleave // gap between files
+
+		rev.After = revolutions(f.positions, len(slots))
+		report.Files = append(report.Files, rev)
+	}
+
+	if report.Moved == 0 {
+		return report, nil
+	}
+
+	for pos, ix := range slots {
+		r := placed[pos]
+		if r == nil {
+			var err error
+			if r, err = newBlankRecord(); err != nil {
+				return nil, err
+			}
+		}
+		s, err := base.NewSector(cart.GetSectorAt(ix).Header(), r)
+		if err != nil {
+			return nil, err
+		}
+		cart.SetSectorAt(ix, s)
+	}
+
+	cart.SetModified(true)
+	return report, nil
+}
+
+// revolutions estimates the number of cartridge revolutions needed for reading
+// records located at the given positions in access order, in sequence, on a
+// cartridge with given number of sectors. Reading starts when the first record
+// passes by the head. A record following its predecessor closer than the
+// interleave is assumed to be missed, and needs another revolution. The result
+// is rounded to two decimals.
+func revolutions(positions []int, sectors int) float64 {
+
+	if len(positions) == 0 || sectors == 0 {
+		return 0
+	}
+
+	total := 1
+	for ix := 1; ix < len(positions); ix++ {
+		d := (positions[ix] - positions[ix-1] + sectors) % sectors
+		if d < defragInterleave {
+			d += sectors
+		}
+		total += d
+	}
+
+	return math.Round(100*float64(total)/float64(sectors)) / 100
+}
+
+// byRecordIndex sorts records by record index, along with their positions
+type byRecordIndex struct {
+	records   []*record
+	positions []int
+}
+
+func (b byRecordIndex) Len() int {
+	return len(b.records)
+}
+
+func (b byRecordIndex) Less(i, j int) bool {
+	return b.records[i].Index() < b.records[j].Index()
+}
+
+func (b byRecordIndex) Swap(i, j int) {
+	b.records[i], b.records[j] = b.records[j], b.records[i]
+	b.positions[i], b.positions[j] = b.positions[j], b.positions[i]
+}
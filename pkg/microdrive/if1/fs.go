@@ -79,7 +79,7 @@ func (fs *fsys) Open(name string) (*base.File, error) {
 	}
 
 	if len(records) == 0 {
-		return nil, fmt.Errorf("file not found")
+		return nil, base.ErrFileNotFound
 	}
 
 	impl := &file{}
@@ -94,11 +94,13 @@ func (fs *fsys) Ls() (*base.FsStats, []*base.FileInfo, error) {
 
 	dir := make(map[string]int)
 	anno := make(map[string]util.Annotations)
+	sectors := 0
 	used := 0
 
 	for ix := 0; ix < fs.cart.SectorCount(); ix++ {
 
-		if sec := fs.cart.GetNextSector(); sec != nil {
+		if sec := fs.cart.GetSectorAt(ix); sec != nil {
+			sectors++
 			if rec := sec.Record(); rec != nil {
 
 				if rec.Flags()&RecordFlagsUsed == 0 {
@@ -138,7 +140,217 @@ func (fs *fsys) Ls() (*base.FsStats, []*base.FileInfo, error) {
 		ret[ix].Annotations = anno[name]
 	}
 
-	return base.NewFsStats(fs.cart.SectorCount(), used), ret, nil
+	return base.NewFsStats(sectors, used), ret, nil
+}
+
+/*
+	Create creates a new file with given name and data on the cartridge. The
+	file is written into free sectors, in the order in which they pass by the
+	drive head. These parameters are used for the file header, if present:
+
+		fileType		0 = BASIC, 1 = number array, 2 = character array,
+						3 = code (default)
+		startAddress	defaults to 23755 for BASIC, 32768 otherwise
+		programLength	BASIC only, length of the program without variables;
+						defaults to data length
+		lineNumber		BASIC only, auto-run line; defaults to no auto-run
+*/
+func (fs *fsys) Create(name string, data []byte, p util.Params) error {
+
+	if fs.cart.IsWriteProtected() {
+		return base.ErrWriteProtected
+	}
+
+	if err := ValidateName(name); err != nil {
+		return err
+	}
+
+	if _, err := fs.Open(name); err == nil {
+		return fmt.Errorf("%w: %s", base.ErrFileExists, name)
+	}
+
+	if len(data) > 0xffff {
+		return fmt.Errorf("%w: %d bytes", base.ErrFileTooLarge, len(data))
+	}
+
+	payload := append(newFileHeader(len(data), p), data...)
+	count := (len(payload) + base.FileBlockLength - 1) / base.FileBlockLength
+
+	free := fs.freeSectors()
+	if len(free) < count {
+		return fmt.Errorf("%w: need %d sectors, have %d",
+			base.ErrNoSpace, count, len(free))
+	}
+
+	for ix := 0; ix < count; ix++ {
+
+		end := (ix + 1) * base.FileBlockLength
+		if end > len(payload) {
+			end = len(payload)
+		}
+
+		rec, err := newFileRecord(
+			name, ix, payload[ix*base.FileBlockLength:end], ix == count-1)
+		if err != nil {
+			return fmt.Errorf("error creating record %d: %v", ix, err)
+		}
+		free[ix].SetRecord(rec)
+	}
+
+	fs.cart.SetModified(true)
+	return nil
+}
+
+// Delete erases the file with given name by marking all its records as free.
+func (fs *fsys) Delete(name string) error {
+
+	if fs.cart.IsWriteProtected() {
+		return base.ErrWriteProtected
+	}
+
+	records := fs.fileRecords(name)
+	if len(records) == 0 {
+		return base.ErrFileNotFound
+	}
+
+	for _, r := range records {
+		if err := r.erase(); err != nil {
+			return fmt.Errorf("error erasing record %d: %v", r.Index(), err)
+		}
+	}
+
+	fs.cart.SetModified(true)
+	return nil
+}
+
+// Rename renames file oldName to newName in all its records.
+func (fs *fsys) Rename(oldName, newName string) error {
+
+	if fs.cart.IsWriteProtected() {
+		return base.ErrWriteProtected
+	}
+
+	if err := ValidateName(newName); err != nil {
+		return err
+	}
+
+	records := fs.fileRecords(oldName)
+	if len(records) == 0 {
+		return base.ErrFileNotFound
+	}
+
+	if oldName != newName && len(fs.fileRecords(newName)) > 0 {
+		return fmt.Errorf("%w: %s", base.ErrFileExists, newName)
+	}
+
+	for _, r := range records {
+		if err := r.rename(newName); err != nil {
+			return fmt.Errorf("error renaming record %d: %v", r.Index(), err)
+		}
+	}
+
+	fs.cart.SetModified(true)
+	return nil
+}
+
+// fileRecords returns all used records belonging to the file with given name,
+// in no particular order
+func (fs *fsys) fileRecords(name string) []*record {
+
+	var ret []*record
+
+	for ix := 0; ix < fs.cart.SectorCount(); ix++ {
+		if s := fs.cart.GetSectorAt(ix); s != nil {
+			if r, ok := s.Record().(*record); ok && r != nil && r.isUsed() &&
+				strings.TrimSpace(translate(r.Name())) == name {
+				ret = append(ret, r)
+			}
+		}
+	}
+
+	return ret
+}
+
+// freeSectors returns all formatted sectors that do not contain a used record,
+// in descending order of sector number, i.e. the order in which they pass by
+// the drive head.
+func (fs *fsys) freeSectors() []base.Sector {
+
+	var ret []base.Sector
+
+	for ix := 0; ix < fs.cart.SectorCount(); ix++ {
+		if s := fs.cart.GetSectorAt(ix); s != nil {
+			if r, ok := s.Record().(*record); !ok || r == nil || !r.isUsed() {
+				ret = append(ret, s)
+			}
+		}
+	}
+
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].Index() > ret[j].Index()
+	})
+
+	return ret
+}
+
+//
+func newFileHeader(length int, p util.Params) []byte {
+
+	typ, ok := p.GetInt("fileType")
+	if !ok {
+		typ = 3
+	}
+
+	start, ok := p.GetInt("startAddress")
+	if !ok {
+		if typ == 0 {
+			start = 23755
+		} else {
+			start = 32768
+		}
+	}
+
+	progLength := 0xffff
+	line := 0xffff
+
+	if typ == 0 {
+		if progLength, ok = p.GetInt("programLength"); !ok {
+			progLength = length
+		}
+		if line, ok = p.GetInt("lineNumber"); !ok {
+			line = 0xffff
+		}
+	}
+
+	return []byte{
+		byte(typ),
+		byte(length), byte(length >> 8),
+		byte(start), byte(start >> 8),
+		byte(progLength), byte(progLength >> 8),
+		byte(line), byte(line >> 8),
+	}
+}
+
+// ValidateName checks whether name is a valid file name
+func ValidateName(name string) error {
+
+	if name == "" || len(name) > 10 {
+		return fmt.Errorf(
+			"%w: needs to have 1 through 10 characters", base.ErrInvalidName)
+	}
+
+	if strings.TrimSpace(name) != name {
+		return fmt.Errorf(
+			"%w: must not start or end with spaces", base.ErrInvalidName)
+	}
+
+	for _, c := range name {
+		if c < '\x20' || c > '\x7e' {
+			return fmt.Errorf("%w: invalid character %q", base.ErrInvalidName, c)
+		}
+	}
+
+	return nil
 }
 
 //
@@ -162,3 +374,40 @@ func fileAnnotations(r *record) util.Annotations {
 
 	return a
 }
+
+// FileParams returns the parameters for Create that reproduce the file header
+// contained in record r, which needs to be the first record of a file
+func FileParams(r base.Record) util.Params {
+	p := util.Params{}
+	if rec, ok := r.(*record); ok && rec.Flags()&RecordFlagData != 0 {
+		p["fileType"] = int(rec.block.GetByte("fileType"))
+		p["startAddress"] = rec.block.GetInt("startAddress")
+		p["programLength"] = rec.block.GetInt("programLength")
+		p["lineNumber"] = rec.block.GetInt("lineNumber")
+	}
+	return p
+}
+
+// UnwrapFile checks whether data starts with a file header whose data length
+// matches the remaining data, as is the case for files exported with header
+// wrapper. If so, the file data following the header is returned, together
+// with the parameters for Create that reproduce the header.
+func UnwrapFile(data []byte) ([]byte, util.Params, bool) {
+
+	if len(data) < FileHeaderLength || data[0] > 3 {
+		return nil, nil, false
+	}
+
+	le := func(ix int) int { return int(data[ix]) | int(data[ix+1])<<8 }
+
+	if le(1) != len(data)-FileHeaderLength {
+		return nil, nil, false
+	}
+
+	return data[FileHeaderLength:], util.Params{
+		"fileType":      int(data[0]),
+		"startAddress":  le(3),
+		"programLength": le(5),
+		"lineNumber":    le(7),
+	}, true
+}
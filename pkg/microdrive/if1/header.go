@@ -64,6 +64,27 @@ func NewHeader(data []byte, isRaw bool) (*header, error) {
 	return h, h.Validate()
 }
 
+// newHeader creates a sector header for sector number with given cartridge name
+func newHeader(name string, number int) (*header, error) {
+
+	h := &header{
+		block: raw.NewBlock(headerIndex, make([]byte, HeaderLength)),
+	}
+	raw.CopySyncPattern(h.block.Data)
+
+	if err := h.block.SetByte("flags", 0x01); err != nil {
+		return nil, err
+	}
+	if err := h.block.SetByte("number", byte(number)); err != nil {
+		return nil, err
+	}
+	if err := h.block.SetString("name", fmt.Sprintf("%-10s", name)); err != nil {
+		return nil, err
+	}
+
+	return h, h.FixChecksum()
+}
+
 //
 func (h *header) Client() client.Client {
 	return client.IF1
@@ -79,6 +100,11 @@ func (h *header) Demuxed() []byte {
 	return h.block.Data
 }
 
+//
+func (h *header) Block() *raw.Block {
+	return h.block
+}
+
 //
 func (h *header) mux() {
 	h.muxed = raw.Mux(h.block.Data, false)
@@ -118,6 +144,14 @@ func (h *header) FixChecksum() error {
 	return h.Validate()
 }
 
+// setName sets the cartridge name in this header
+func (h *header) setName(name string) error {
+	if err := h.block.SetString("name", fmt.Sprintf("%-10s", name)); err != nil {
+		return err
+	}
+	return h.FixChecksum()
+}
+
 //
 func (h *header) Validate() error {
 
@@ -25,6 +25,7 @@ import (
 	"fmt"
 	"io"
 
+	"github.com/xelalexv/oqtadrive/pkg/microdrive/base"
 	"github.com/xelalexv/oqtadrive/pkg/microdrive/client"
 	"github.com/xelalexv/oqtadrive/pkg/microdrive/raw"
 )
@@ -94,6 +95,70 @@ func NewRecord(data []byte, isRaw bool) (*record, error) {
 	return r, r.Validate()
 }
 
+// newBlankRecord creates an unused record, as written by FORMAT
+func newBlankRecord() (*record, error) {
+	r := &record{
+		block: raw.NewBlock(recordIndex, make([]byte, RecordLength)),
+	}
+	raw.CopySyncPattern(r.block.Data)
+	return r, r.FixChecksums()
+}
+
+// newFileRecord creates a record with number ix for the file with given name,
+// containing the provided file block. If ix is 0, block needs to start with
+// the file header. last denotes whether this is the last record of the file.
+func newFileRecord(name string, ix int, block []byte, last bool) (*record, error) {
+
+	if len(block) > base.FileBlockLength {
+		return nil, fmt.Errorf("file block too long: %d", len(block))
+	}
+
+	r, err := newBlankRecord()
+	if err != nil {
+		return nil, err
+	}
+
+	var flags byte = RecordFlagData
+	if last {
+		flags |= RecordFlagEOF
+	}
+
+	if err := r.block.SetByte("flags", flags); err != nil {
+		return nil, err
+	}
+	if err := r.block.SetByte("number", byte(ix)); err != nil {
+		return nil, err
+	}
+	if err := r.block.SetInt("length", len(block)); err != nil {
+		return nil, err
+	}
+	if err := r.block.SetString("name", fmt.Sprintf("%-10s", name)); err != nil {
+		return nil, err
+	}
+	copy(r.Data(), block)
+
+	return r, r.FixChecksums()
+}
+
+// erase marks this record as free, as the ROM does when erasing a file
+func (r *record) erase() error {
+	if err := r.block.SetByte("flags", 0); err != nil {
+		return err
+	}
+	if err := r.block.SetInt("length", 0); err != nil {
+		return err
+	}
+	return r.FixChecksums()
+}
+
+//
+func (r *record) rename(name string) error {
+	if err := r.block.SetString("name", fmt.Sprintf("%-10s", name)); err != nil {
+		return err
+	}
+	return r.FixChecksums()
+}
+
 //
 func (r *record) Client() client.Client {
 	return client.IF1
@@ -109,6 +174,11 @@ func (r *record) Demuxed() []byte {
 	return r.block.Data
 }
 
+//
+func (r *record) Block() *raw.Block {
+	return r.block
+}
+
 //
 func (r *record) mux() {
 	r.muxed = raw.Mux(r.block.Data, false)
@@ -119,6 +189,14 @@ func (r *record) Flags() byte {
 	return r.block.GetByte("flags")
 }
 
+// isUsed determines whether this record belongs to a file. As in the ROM, a
+// record is free only if it is not the last record of a file and has length 0.
+// Checking the flags alone is not sufficient, since the records of a PRINT file
+// other than the last one have no flags set.
+func (r *record) isUsed() bool {
+	return r.Flags()&RecordFlagEOF != 0 || r.Length() != 0
+}
+
 //
 func (r *record) Index() int {
 	return int(r.block.GetByte("number"))
@@ -0,0 +1,423 @@
+/*
+   OqtaDrive - Sinclair Microdrive emulator
+   Copyright (c) 2022, Alexander Vollschwitz
+
+   This file is part of OqtaDrive.
+
+   OqtaDrive is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   OqtaDrive is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with OqtaDrive. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package if1
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// tokens with special treatment during tokenizing
+const (
+	tokenBIN   = 0xc4
+	tokenDEFFN = 0xce
+	tokenREM   = 0xea
+)
+
+// keyword tokens sorted by descending keyword length, so that longest match
+// wins, e.g. GO TO over TO
+var tokensByLength []byte
+
+// Spectrum characters for Unicode characters not in ASCII
+var unicodeChars = map[rune]byte{}
+
+//
+func init() {
+	for t := tokenFirst; t <= 0xff; t++ {
+		tokensByLength = append(tokensByLength, byte(t))
+	}
+	sort.SliceStable(tokensByLength, func(i, j int) bool {
+		return len(keyword(tokensByLength[i])) > len(keyword(tokensByLength[j]))
+	})
+	for c, r := range specialChars {
+		unicodeChars[r] = c
+	}
+	for c := udgFirst; c <= udgLast; c++ {
+		unicodeChars[udgRune+rune(c-udgFirst)] = byte(c)
+	}
+}
+
+// Tokenize turns a BASIC program in text form into a Spectrum BASIC program.
+// Each non-empty line has to start with a line number. Keywords need to be in
+// upper case. Embedded control codes can be given in the form produced by
+// Detokenize, i.e. {INK 2}, {AT 1,3}, or {xx} for any character in hex. The
+// tokenized program and the first line number are returned.
+func Tokenize(text string) ([]byte, int, error) {
+
+	var prog []byte
+	first := -1
+	last := -1
+
+	for ix, l := range strings.Split(text, "\n") {
+
+		l = strings.TrimSpace(l)
+		if l == "" {
+			continue
+		}
+
+		digits := 0
+		for digits < len(l) && '0' <= l[digits] && l[digits] <= '9' {
+			digits++
+		}
+		if digits == 0 {
+			return nil, -1, fmt.Errorf("line %d: missing line number", ix+1)
+		}
+
+		number, err := strconv.Atoi(l[:digits])
+		if err != nil || number < 1 || number > 9999 {
+			return nil, -1, fmt.Errorf(
+				"line %d: invalid line number %s", ix+1, l[:digits])
+		}
+		if number <= last {
+			return nil, -1, fmt.Errorf(
+				"line %d: line number %d not ascending", ix+1, number)
+		}
+
+		body, err := tokenizeLine(strings.TrimPrefix(l[digits:], " "))
+		if err != nil {
+			return nil, -1, fmt.Errorf("line %d: %v", ix+1, err)
+		}
+		body = append(body, markerEnter)
+
+		prog = append(prog, byte(number>>8), byte(number),
+			byte(len(body)), byte(len(body)>>8))
+		prog = append(prog, body...)
+
+		if first == -1 {
+			first = number
+		}
+		last = number
+	}
+
+	if len(prog) == 0 {
+		return nil, -1, fmt.Errorf("empty program")
+	}
+
+	return prog, first, nil
+}
+
+// tokenizer holds the state for tokenizing a single line
+type tokenizer struct {
+	line     string
+	pos      int
+	out      []byte
+	inString bool
+	inREM    bool
+	lastTok  byte
+	fnParams int // 0 = none, 1 = DEF FN seen, 2 = inside parameter list
+}
+
+//
+func tokenizeLine(line string) ([]byte, error) {
+
+	t := &tokenizer{line: line}
+
+	for t.pos < len(t.line) {
+
+		if t.control() {
+			continue
+		}
+
+		c := t.line[t.pos]
+
+		if t.inREM {
+			if err := t.char(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if c == '"' {
+			t.inString = !t.inString
+			t.emit(c)
+			continue
+		}
+
+		if t.inString {
+			if err := t.char(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if t.keyword() {
+			continue
+		}
+
+		switch {
+		case isDigit(c) || c == '.' && t.pos+1 < len(t.line) &&
+			isDigit(t.line[t.pos+1]):
+			if err := t.number(); err != nil {
+				return nil, err
+			}
+
+		case isLetter(c):
+			t.identifier()
+
+		default:
+			if t.fnParams == 2 && c == ')' {
+				t.fnParams = 0
+			}
+			if err := t.char(); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return t.out, nil
+}
+
+//
+func (t *tokenizer) emit(b ...byte) {
+	t.out = append(t.out, b...)
+	t.pos++
+}
+
+// char emits the Spectrum character for the Unicode character at current
+// position
+func (t *tokenizer) char() error {
+
+	r, size := utf8.DecodeRuneInString(t.line[t.pos:])
+
+	if r < utf8.RuneSelf && r >= 0x20 && r != 0x60 && r != 0x7f {
+		t.out = append(t.out, byte(r))
+	} else if c, ok := unicodeChars[r]; ok {
+		t.out = append(t.out, c)
+	} else {
+		return fmt.Errorf("character '%c' not available", r)
+	}
+
+	t.pos += size
+	return nil
+}
+
+// control checks for an embedded control code in braces at current position,
+// and emits it if found
+func (t *tokenizer) control() bool {
+
+	if t.line[t.pos] != '{' {
+		return false
+	}
+
+	end := strings.IndexByte(t.line[t.pos:], '}')
+	if end == -1 {
+		return false
+	}
+
+	code := t.line[t.pos+1 : t.pos+end]
+	var out []byte
+
+	if len(code) == 2 {
+		if v, err := strconv.ParseUint(code, 16, 8); err == nil {
+			out = []byte{byte(v)}
+		}
+
+	} else if fields := strings.SplitN(code, " ", 2); len(fields) == 2 {
+		for c, cc := range controlCodes {
+			if cc.name != fields[0] {
+				continue
+			}
+			var args []int
+			for _, a := range strings.Split(fields[1], ",") {
+				v, err := strconv.Atoi(strings.TrimSpace(a))
+				if err != nil || v < 0 || v > 255 {
+					return false
+				}
+				args = append(args, v)
+			}
+			switch {
+			case c == 0x17 && len(args) == 1: // TAB takes a 16 bit value
+				out = []byte{c, byte(args[0]), 0}
+			case len(args) == cc.params && c != 0x17:
+				out = []byte{c}
+				for _, a := range args {
+					out = append(out, byte(a))
+				}
+			}
+			break
+		}
+	}
+
+	if out == nil {
+		return false
+	}
+
+	t.out = append(t.out, out...)
+	t.pos += end + 1
+	return true
+}
+
+// keyword checks for a keyword at current position, and emits its token if
+// found. Spaces around the keyword that Detokenize would add are dropped.
+func (t *tokenizer) keyword() bool {
+
+	afterAlnum := t.pos > 0 && isAlnum(t.line[t.pos-1])
+
+	for _, tok := range tokensByLength {
+
+		kw := keyword(tok)
+		if !strings.HasPrefix(t.line[t.pos:], kw) ||
+			afterAlnum && isLetter(kw[0]) {
+			continue
+		}
+
+		end := t.pos + len(kw)
+		if isLetter(kw[len(kw)-1]) && end < len(t.line) &&
+			isLetter(t.line[end]) {
+			continue
+		}
+
+		if leadingSpace(tok) && len(t.out) > 0 && t.out[len(t.out)-1] == ' ' {
+			t.out = t.out[:len(t.out)-1]
+		}
+
+		t.out = append(t.out, tok)
+		t.pos = end
+		t.lastTok = tok
+
+		if last := kw[len(kw)-1]; tok > 0xa7 && (last == '$' || last >= 'A') &&
+			t.pos < len(t.line) && t.line[t.pos] == ' ' {
+			t.pos++
+		}
+
+		switch tok {
+		case tokenREM:
+			t.inREM = true
+		case tokenDEFFN:
+			t.fnParams = 1
+		}
+
+		return true
+	}
+
+	return false
+}
+
+// identifier emits a variable name; for DEF FN parameters, the hidden number
+// placeholders are inserted
+func (t *tokenizer) identifier() {
+
+	start := t.pos
+	for t.pos < len(t.line) && isAlnum(t.line[t.pos]) {
+		t.pos++
+	}
+	if t.pos < len(t.line) && t.line[t.pos] == '$' {
+		t.pos++
+	}
+	t.out = append(t.out, t.line[start:t.pos]...)
+	t.lastTok = 0
+
+	switch t.fnParams {
+	case 1:
+		if t.pos < len(t.line) && t.line[t.pos] == '(' {
+			t.out = append(t.out, '(')
+			t.pos++
+			t.fnParams = 2
+		} else {
+			t.fnParams = 0
+		}
+	case 2:
+		t.out = append(t.out, markerNumber, 0, 0, 0, 0, 0)
+	}
+}
+
+// number emits a numeric literal, followed by its hidden binary form
+func (t *tokenizer) number() error {
+
+	start := t.pos
+	var v float64
+
+	if t.lastTok == tokenBIN {
+		for t.pos < len(t.line) && (t.line[t.pos] == '0' || t.line[t.pos] == '1') {
+			v = 2*v + float64(t.line[t.pos]-'0')
+			t.pos++
+		}
+
+	} else {
+		for t.pos < len(t.line) && (isDigit(t.line[t.pos]) || t.line[t.pos] == '.') {
+			t.pos++
+		}
+		if t.pos < len(t.line) && (t.line[t.pos] == 'e' || t.line[t.pos] == 'E') {
+			exp := t.pos + 1
+			if exp < len(t.line) && (t.line[exp] == '+' || t.line[exp] == '-') {
+				exp++
+			}
+			if exp < len(t.line) && isDigit(t.line[exp]) {
+				for t.pos = exp; t.pos < len(t.line) && isDigit(t.line[t.pos]); {
+					t.pos++
+				}
+			}
+		}
+		var err error
+		if v, err = strconv.ParseFloat(t.line[start:t.pos], 64); err != nil {
+			return fmt.Errorf("invalid number %s", t.line[start:t.pos])
+		}
+	}
+
+	t.out = append(t.out, t.line[start:t.pos]...)
+	t.out = append(t.out, markerNumber)
+	t.out = append(t.out, encodeNumber(v)...)
+	t.lastTok = 0
+
+	return nil
+}
+
+// encodeNumber encodes a non-negative number in Spectrum 5 byte format, using
+// small integer form where possible
+func encodeNumber(v float64) []byte {
+
+	if v == math.Trunc(v) && v < 0x10000 {
+		i := int(v)
+		return []byte{0, 0, byte(i), byte(i >> 8), 0}
+	}
+
+	frac, exp := math.Frexp(v)
+	m := uint64(math.Round(frac * (1 << 32)))
+	if m >= 1<<32 {
+		m >>= 1
+		exp++
+	}
+	if exp+128 > 0xff {
+		exp, m = 0xff-128, 0xffffffff
+	}
+
+	return []byte{byte(exp + 128), byte(m>>24) & 0x7f, byte(m >> 16),
+		byte(m >> 8), byte(m)}
+}
+
+//
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+//
+func isLetter(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+//
+func isAlnum(c byte) bool {
+	return isDigit(c) || isLetter(c)
+}
@@ -21,6 +21,10 @@
 package ql
 
 import (
+	"fmt"
+	"math/rand"
+	"time"
+
 	"github.com/xelalexv/oqtadrive/pkg/microdrive/base"
 	"github.com/xelalexv/oqtadrive/pkg/microdrive/client"
 )
@@ -33,6 +37,76 @@ func NewCartridge() *base.Cartridge {
 	return cart
 }
 
+// NewFormattedCartridge creates a cartridge as left behind by FORMAT, with
+// given name and number of sectors. Sectors are numbered 0 through sectors-1,
+// as happens on a real tape shorter than the maximum sector count. Sector 0
+// holds the sector map, the top-most sector the empty directory.
+func NewFormattedCartridge(name string, sectors int) (*base.Cartridge, error) {
+
+	if sectors < 2 || sectors > SectorCount {
+		return nil, fmt.Errorf(
+			"sector count needs to be between 2 and %d", SectorCount)
+	}
+
+	if len(name) > 10 {
+		return nil, fmt.Errorf("cartridge name must not exceed 10 characters")
+	}
+
+	random := rand.New(rand.NewSource(time.Now().UnixNano())).Intn(0x10000)
+	dir := sectors - 1
+
+	sectorMap := make([]byte, base.FileBlockLength)
+	for s := 0; s < SectorCount; s++ {
+		switch {
+		case s == 0:
+			sectorMap[0] = FileNumberMap
+		case s == dir:
+			sectorMap[2*s] = FileNumberDirectory
+		case s < sectors:
+			sectorMap[2*s] = FileNumberFree
+		default:
+			sectorMap[2*s] = FileNumberUnavailable
+		}
+	}
+
+	dirHeader := make([]byte, FileHeaderLength)
+	putLength(dirHeader, FileHeaderLength)
+
+	cart := NewCartridge()
+
+	for s := 0; s < sectors; s++ {
+
+		hd, err := newHeader(name, s, random)
+		if err != nil {
+			return nil, err
+		}
+
+		rec, err := newBlankRecord()
+		if err == nil {
+			switch s {
+			case 0:
+				err = rec.setBlock(FileNumberMap, 0, sectorMap)
+			case dir:
+				err = rec.setBlock(FileNumberDirectory, 0, dirHeader)
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		sec, err := base.NewSector(hd, rec)
+		if err != nil {
+			return nil, err
+		}
+		cart.SetSectorAt(s, sec)
+	}
+
+	cart.SetName(fmt.Sprintf("%-10s", name))
+	cart.SeekToStart()
+
+	return cart, nil
+}
+
 //
 type cartridge struct {
 	*base.Cartridge
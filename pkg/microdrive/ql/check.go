@@ -0,0 +1,295 @@
+/*
+   OqtaDrive - Sinclair Microdrive emulator
+   Copyright (c) 2022, Alexander Vollschwitz
+
+   This file is part of OqtaDrive.
+
+   OqtaDrive is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   OqtaDrive is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with OqtaDrive. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package ql
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+
+	"github.com/xelalexv/oqtadrive/pkg/microdrive/base"
+)
+
+/*
+	Check checks the file system for these problems:
+
+		- sector headers with a cartridge name differing from the majority
+		- missing or duplicate zero blocks, i.e. sector maps
+		- map entries pointing at missing or free sectors, or at sectors holding
+		  a different block
+		- orphaned blocks, i.e. blocks of files not in the directory, or beyond
+		  the length of a file
+		- files with missing or duplicate blocks
+		- directory entries whose name or length differs from the file header
+
+	When repair is set, deviating cartridge names are corrected, orphaned blocks
+	are freed, and of duplicate blocks with identical content, all but one are
+	freed.
+*/
+func (fs *fsys) Check(repair bool) (*base.CheckReport, error) {
+
+	if repair && fs.cart.IsWriteProtected() {
+		return nil, fmt.Errorf("cartridge is write protected")
+	}
+
+	report := base.NewCheckReport()
+
+	var rename func(h base.Header, name string) error
+	if repair {
+		rename = func(h base.Header, name string) error {
+			if hd, ok := h.(*header); ok {
+				return hd.setName(name)
+			}
+			return fmt.Errorf("not a QL header")
+		}
+	}
+	base.CheckHeaderNames(fs.cart, report, rename)
+
+	index, zero := fs.zeroBlocks()
+	if len(zero) == 0 {
+		report.Add(base.ProblemMissingMap, "", 0, false, "zero block not found")
+		return fs.finishCheck(report, nil)
+	}
+	for _, z := range zero[1:] {
+		report.Add(base.ProblemDuplicateZero, "", z.Index(), false,
+			"additional zero block found")
+	}
+
+	sm, err := newSectorMap(fs.cart, zero[0], index)
+	if err != nil {
+		report.Add(base.ProblemMissingMap, "", 0, false, "%v", err)
+		return fs.finishCheck(report, nil)
+	}
+
+	blocks := fs.checkMapEntries(sm, report)
+
+	dir, err := newDirectory(sm)
+	if err != nil {
+		report.Add(base.ProblemDirectory, "", -1, false, "%v", err)
+		return fs.finishCheck(report, sm)
+	}
+
+	var numbers []int
+	for f := range blocks {
+		numbers = append(numbers, f)
+	}
+	sort.Ints(numbers)
+
+	for _, f := range numbers {
+		if err := fs.checkFile(f, blocks[f], sm, dir, report, repair); err != nil {
+			return nil, err
+		}
+	}
+
+	for f := 1; (f+1)*FileHeaderLength <= dir.length; f++ {
+		if _, ok := blocks[f]; !ok && !dir.isFree(f) {
+			report.Add(base.ProblemMissingRecord, entryName(dir.entry(f)), -1,
+				false, "file %d in directory, but has no blocks", f)
+		}
+	}
+
+	return fs.finishCheck(report, sm)
+}
+
+// checkMapEntries checks that all map entries allocated to a file point at a
+// sector holding the according block, and returns the sector numbers of each
+// file's blocks, as file number -> block number -> sector numbers
+func (fs *fsys) checkMapEntries(sm *sectorMap,
+	report *base.CheckReport) map[int]map[int][]int {
+
+	blocks := make(map[int]map[int][]int)
+
+	for s := SectorCount - 1; s >= 0; s-- {
+
+		f, b := sm.getSector(s)
+		if f > FileNumberMaxRegular {
+			continue
+		}
+
+		var r *record
+		if ix, ok := sm.index[s]; ok {
+			r, _ = fs.cart.GetSectorAt(ix).Record().(*record)
+		}
+
+		switch {
+		case r == nil:
+			report.Add(base.ProblemMissingSector, "", s, false,
+				"map entry for file %d, block %d points at missing sector",
+				f, b)
+			continue
+		case r.Flags() == FileNumberFree:
+			report.Add(base.ProblemFreeSector, "", s, false,
+				"map entry for file %d, block %d points at free sector", f, b)
+			continue
+		case int(r.Flags()) != f || r.Index() != b:
+			report.Add(base.ProblemBlockMismatch, "", s, false,
+				"map entry is file %d, block %d, but sector holds file %d, "+
+					"block %d", f, b, r.Flags(), r.Index())
+			continue
+		}
+
+		if blocks[f] == nil {
+			blocks[f] = make(map[int][]int)
+		}
+		blocks[f][b] = append(blocks[f][b], s)
+	}
+
+	return blocks
+}
+
+// checkFile checks the blocks of file number f against its directory entry
+func (fs *fsys) checkFile(f int, blocks map[int][]int, sm *sectorMap,
+	dir *directory, report *base.CheckReport, repair bool) error {
+
+	free := func(s int) (bool, error) {
+		if !repair {
+			return false, nil
+		}
+		if err := sm.setBlock(s, FileNumberFree, 0, nil); err != nil {
+			return false, fmt.Errorf("error freeing sector %d: %v", s, err)
+		}
+		return true, nil
+	}
+
+	var name string
+	var length int
+
+	if f == FileNumberDirectory {
+		name = "directory"
+		length = dir.length
+
+	} else if dir.isFree(f) {
+		for _, b := range blockNumbers(blocks) {
+			for _, s := range blocks[b] {
+				repaired, err := free(s)
+				if err != nil {
+					return err
+				}
+				report.Add(base.ProblemOrphanedBlock, "", s, repaired,
+					"block %d of file %d, which is not in directory", b, f)
+			}
+		}
+		return nil
+
+	} else {
+		e := dir.entry(f)
+		name = entryName(e)
+		length = entryLength(e)
+	}
+
+	count := (length + base.FileBlockLength - 1) / base.FileBlockLength
+	if count == 0 {
+		count = 1
+	}
+
+	for _, b := range blockNumbers(blocks) {
+		if b < count {
+			continue
+		}
+		for _, s := range blocks[b] {
+			repaired, err := free(s)
+			if err != nil {
+				return err
+			}
+			report.Add(base.ProblemOrphanedBlock, name, s, repaired,
+				"block %d beyond file length %d", b, length)
+		}
+	}
+
+	for b := 0; b < count; b++ {
+
+		sectors := blocks[b] // in descending order, i.e. as passing by head
+		if len(sectors) == 0 {
+			report.Add(base.ProblemMissingRecord, name, -1, false,
+				"block %d missing", b)
+			continue
+		}
+
+		for _, s := range sectors[1:] {
+			repaired := false
+			if sameBlock(sm, sectors[0], s) {
+				var err error
+				if repaired, err = free(s); err != nil {
+					return err
+				}
+			}
+			report.Add(base.ProblemDuplicateRecord, name, s, repaired,
+				"block %d also present in sector %d", b, sectors[0])
+		}
+	}
+
+	if f == FileNumberDirectory || len(blocks[0]) == 0 {
+		return nil
+	}
+
+	first, _ := fs.cart.GetSectorAt(sm.index[blocks[0][0]]).Record().(*record)
+	if first == nil {
+		return nil
+	}
+
+	if n := first.Name(); n != name {
+		report.Add(base.ProblemNameMismatch, name, blocks[0][0], false,
+			"file header has name '%s'", n)
+	}
+	if l := first.Length(); l != length {
+		report.Add(base.ProblemLengthMismatch, name, blocks[0][0], false,
+			"directory states length %d, file header %d", length, l)
+	}
+
+	return nil
+}
+
+// blockNumbers returns the block numbers contained in blocks, in ascending order
+func blockNumbers(blocks map[int][]int) []int {
+	var ret []int
+	for b := range blocks {
+		ret = append(ret, b)
+	}
+	sort.Ints(ret)
+	return ret
+}
+
+// sameBlock determines whether sectors a and b hold identical data
+func sameBlock(sm *sectorMap, a, b int) bool {
+	ra, _ := sm.cart.GetSectorAt(sm.index[a]).Record().(*record)
+	rb, _ := sm.cart.GetSectorAt(sm.index[b]).Record().(*record)
+	return ra != nil && rb != nil && bytes.Equal(ra.Data(), rb.Data())
+}
+
+// finishCheck finalizes a check; if problems were repaired, the sector map is
+// updated and the cartridge marked as modified
+func (fs *fsys) finishCheck(report *base.CheckReport,
+	sm *sectorMap) (*base.CheckReport, error) {
+
+	if report.Repaired() == 0 {
+		return report, nil
+	}
+
+	if sm == nil {
+		fs.cart.SetModified(true)
+		return report, nil
+	}
+
+	if err := fs.finishUpdate(sm); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
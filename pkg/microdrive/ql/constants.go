@@ -37,6 +37,20 @@ const SectorCount = 255
 
 const FileHeaderLength = 64 // only in first record of each file
 
+// size of display memory, and hence of screen dump files
+const ScreenSize = 32768
+
+// special file numbers in sector map; regular files use 1 through 0xef, 0 is
+// the directory
+const FileNumberDirectory = 0x00
+const FileNumberMaxRegular = 0xef
+const FileNumberMap = 0xf8
+const FileNumberFree = 0xfd
+const FileNumberUnavailable = 0xff
+
+// QDOS dates count seconds since 1961-01-01, this is the offset to Unix time
+const DateOffset = 283996800
+
 //
 func toQLCheckSum(sum int) int {
 	return (0x0f0f + sum) % 0x10000
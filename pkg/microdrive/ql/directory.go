@@ -0,0 +1,162 @@
+/*
+   OqtaDrive - Sinclair Microdrive emulator
+   Copyright (c) 2022, Alexander Vollschwitz
+
+   This file is part of OqtaDrive.
+
+   OqtaDrive is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   OqtaDrive is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with OqtaDrive. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package ql
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/xelalexv/oqtadrive/pkg/microdrive/base"
+)
+
+// directory is the QDOS directory, i.e. file number 0. It consists of 64 byte
+// entries, each being a copy of the file header of the file with the entry's
+// number. Entry 0 is the directory's own header, whose length field denotes
+// the length of the directory.
+type directory struct {
+	sm      *sectorMap
+	records []*record
+	entries []byte
+	length  int
+}
+
+//
+func newDirectory(sm *sectorMap) (*directory, error) {
+
+	recs, err := sm.collectFileRecords(FileNumberDirectory)
+	if err != nil {
+		return nil, fmt.Errorf("error reading directory: %v", err)
+	}
+	if len(recs) == 0 {
+		return nil, fmt.Errorf("directory not found")
+	}
+
+	d := &directory{sm: sm}
+
+	for ix, r := range recs {
+		if r == nil {
+			return nil, fmt.Errorf("directory block %d missing", ix)
+		}
+		d.records = append(d.records, r.(*record))
+		d.entries = append(d.entries, r.(*record).Data()...)
+	}
+
+	d.length = d.records[0].Length()
+	if d.length < FileHeaderLength || d.length > len(d.entries) {
+		return nil, fmt.Errorf("invalid directory length: %d", d.length)
+	}
+
+	return d, nil
+}
+
+// find returns the number of the file with given name, or -1 if there is no
+// such file; as in QDOS, names are not case sensitive
+func (d *directory) find(name string) int {
+	for n := 1; (n+1)*FileHeaderLength <= d.length; n++ {
+		if e := d.entry(n); entryLength(e) > 0 &&
+			strings.EqualFold(entryName(e), name) {
+			return n
+		}
+	}
+	return -1
+}
+
+// isFree determines whether entry with given number is unused or beyond the
+// end of the directory
+func (d *directory) isFree(number int) bool {
+	return (number+1)*FileHeaderLength > d.length ||
+		entryLength(d.entry(number)) == 0
+}
+
+//
+func (d *directory) entry(number int) []byte {
+	off := number * FileHeaderLength
+	return d.entries[off : off+FileHeaderLength]
+}
+
+// setEntry sets the entry for given file number, growing the directory if
+// needed; passing nil clears the entry
+func (d *directory) setEntry(number int, e []byte) {
+
+	if l := (number + 1) * FileHeaderLength; l > d.length {
+		d.length = l
+		// entries may already have grown beyond the records, when entries
+		// were set before writing back
+		if missing := d.blockCount()*base.FileBlockLength -
+			len(d.entries); missing > 0 {
+			d.entries = append(d.entries, make([]byte, missing)...)
+		}
+	}
+
+	entry := d.entry(number)
+	n := copy(entry, e)
+	for ix := n; ix < len(entry); ix++ {
+		entry[ix] = 0
+	}
+
+	putLength(d.entries, d.length)
+}
+
+//
+func (d *directory) blockCount() int {
+	return (d.length + base.FileBlockLength - 1) / base.FileBlockLength
+}
+
+// blocksNeeded returns the number of additional blocks needed for adding an
+// entry with given file number
+func (d *directory) blocksNeeded(number int) int {
+	l := (number + 1) * FileHeaderLength
+	if l <= d.length {
+		return 0
+	}
+	if n := (l+base.FileBlockLength-1)/base.FileBlockLength -
+		len(d.records); n > 0 {
+		return n
+	}
+	return 0
+}
+
+// write writes the directory back to its records, using sectors from free for
+// any additional blocks; the remaining free sectors are returned
+func (d *directory) write(free []int) ([]int, error) {
+
+	for ix := 0; ix < d.blockCount(); ix++ {
+
+		block := d.entries[ix*base.FileBlockLength : (ix+1)*base.FileBlockLength]
+		var err error
+
+		if ix < len(d.records) {
+			err = d.records[ix].setBlock(FileNumberDirectory, ix, block)
+		} else if len(free) == 0 {
+			err = fmt.Errorf("no free sector")
+		} else {
+			err = d.sm.setBlock(free[0], FileNumberDirectory, ix, block)
+			free = free[1:]
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf(
+				"error writing directory block %d: %v", ix, err)
+		}
+	}
+
+	return free, nil
+}
@@ -23,8 +23,10 @@ package ql
 import (
 	"fmt"
 	"sort"
+	"time"
 
 	"github.com/xelalexv/oqtadrive/pkg/microdrive/base"
+	"github.com/xelalexv/oqtadrive/pkg/util"
 )
 
 //
@@ -50,26 +52,18 @@ type fsys struct {
 //
 func (fs *fsys) Open(name string) (*base.File, error) {
 
-	index := make(map[int]int)
-	var zero base.Sector
+	sm, err := fs.sectorMap()
+	if err != nil {
+		return nil, err
+	}
+
 	var first *record
 
 	for ix := 0; ix < fs.cart.SectorCount(); ix++ {
-
 		if s := fs.cart.GetSectorAt(ix); s != nil {
-			index[s.Index()] = ix
-			if r := s.Record().(*record); r != nil {
-				// documentation states that sector map is located in sector 0,
-				// bearing file number 0xf8, but 0x80 is also often observed,
-				// not sure why; we need to consider both
-				if s.Index() == 0 && (r.Flags() == 0xF8 || r.Flags() == 0x80) {
-					if zero != nil {
-						return nil, fmt.Errorf("more than one zero block found")
-					}
-					zero = s
-					continue
-				}
-				if r.Flags() > 0xf0 || r.Index() > 0 || r.Name() != name {
+			if r, ok := s.Record().(*record); ok && r != nil {
+				if r == sm.record || r.Flags() > 0xf0 || r.Index() > 0 ||
+					r.Name() != name {
 					continue
 				}
 				if first != nil {
@@ -80,16 +74,8 @@ func (fs *fsys) Open(name string) (*base.File, error) {
 		}
 	}
 
-	if zero == nil {
-		return nil, fmt.Errorf("zero block not found")
-	}
 	if first == nil {
-		return nil, fmt.Errorf("file not found")
-	}
-
-	sm, err := newSectorMap(fs.cart, zero, index)
-	if err != nil {
-		return nil, err
+		return nil, base.ErrFileNotFound
 	}
 
 	records, err := sm.collectFileRecords(int(first.Flags()))
@@ -104,22 +90,66 @@ func (fs *fsys) Open(name string) (*base.File, error) {
 	return ret, nil
 }
 
+// sectorMap locates the zero block and loads the sector map from it
+func (fs *fsys) sectorMap() (*sectorMap, error) {
+
+	index, zero := fs.zeroBlocks()
+
+	if len(zero) == 0 {
+		return nil, fmt.Errorf("zero block not found")
+	}
+	if len(zero) > 1 {
+		return nil, fmt.Errorf("more than one zero block found")
+	}
+
+	return newSectorMap(fs.cart, zero[0], index)
+}
+
+// zeroBlocks returns an index of sector number to slot index, and all sectors
+// that look like a zero block, i.e. contain a sector map
+func (fs *fsys) zeroBlocks() (map[int]int, []base.Sector) {
+
+	index := make(map[int]int)
+	var zero []base.Sector
+
+	for ix := 0; ix < fs.cart.SectorCount(); ix++ {
+
+		if s := fs.cart.GetSectorAt(ix); s != nil {
+			index[s.Index()] = ix
+			if r, ok := s.Record().(*record); ok && r != nil {
+				// documentation states that sector map is located in sector 0,
+				// bearing file number 0xf8, but 0x80 is also often observed,
+				// not sure why; we need to consider both
+				if s.Index() == 0 && (r.Flags() == 0xF8 || r.Flags() == 0x80) {
+					zero = append(zero, s)
+				}
+			}
+		}
+	}
+
+	return index, zero
+}
+
 //
 func (fs *fsys) Ls() (*base.FsStats, []*base.FileInfo, error) {
 
 	dir := make(map[string]int)
-	used := fs.cart.SectorCount()
+	anno := make(map[string]util.Annotations)
+	sectors := 0
+	used := 0
 
 	for ix := 0; ix < fs.cart.SectorCount(); ix++ {
-		if sec := fs.cart.GetNextSector(); sec != nil {
+		if sec := fs.cart.GetSectorAt(ix); sec != nil {
+			sectors++
 			if rec := sec.Record(); rec != nil {
-				if rec.Flags() == 0xfd {
-					used--
+				if rec.Flags() != FileNumberFree {
+					used++
 				}
 				if rec.Flags() > 0xf0 || rec.Index() > 0 {
 					continue
 				}
 				dir[rec.Name()] = rec.Length() // FIXME: do things like this belong into FS methods?
+				anno[rec.Name()] = fileAnnotations(rec.(*record))
 			}
 		}
 	}
@@ -135,9 +165,284 @@ func (fs *fsys) Ls() (*base.FsStats, []*base.FileInfo, error) {
 	ret := make([]*base.FileInfo, len(files))
 	for ix, name := range files {
 		ret[ix] = base.NewFileInfo(name, dir[name])
+		ret[ix].Annotations = anno[name]
+	}
+
+	return base.NewFsStats(sectors, used), ret, nil
+}
+
+//
+func fileAnnotations(r *record) util.Annotations {
+
+	a := make(util.Annotations)
+
+	t := "?"
+	switch r.block.GetByte("fileType") {
+	case 0:
+		t = "data"
+	case 1:
+		t = "exec"
+	case 2:
+		t = "reloc"
+	case 0xff:
+		t = "dir"
+	}
+	a.Annotate("file-type", t)
+	if t == "exec" {
+		a.Annotate("dataspace", r.dataspace())
+	}
+
+	return a
+}
+
+/*
+	Create creates a new file with given name and data. The file is added to
+	the sector map and the directory, and gets a QDOS file header. Supported
+	parameters:
+
+		fileType	0 = BASIC or data (default), 1 = executable, ...
+		dataspace	data space for executable files; defaults to 0
+		dateUpdate	update date as Unix time; defaults to now
+*/
+func (fs *fsys) Create(name string, data []byte, p util.Params) error {
+
+	if fs.cart.IsWriteProtected() {
+		return base.ErrWriteProtected
+	}
+
+	if err := ValidateName(name); err != nil {
+		return err
+	}
+
+	sm, err := fs.sectorMap()
+	if err != nil {
+		return err
+	}
+
+	dir, err := newDirectory(sm)
+	if err != nil {
+		return err
+	}
+
+	if dir.find(name) != -1 {
+		return fmt.Errorf("%w: %s", base.ErrFileExists, name)
+	}
+
+	// pick lowest file number that is neither in use in sector map, nor in
+	// directory
+	number := 1
+	for ; number <= FileNumberMaxRegular; number++ {
+		if !sm.isUsed(number) && dir.isFree(number) {
+			break
+		}
+	}
+	if number > FileNumberMaxRegular {
+		return fmt.Errorf("%w: directory is full", base.ErrNoSpace)
+	}
+
+	payload := append(newFileHeader(name, len(data), p), data...)
+	count := (len(payload) + base.FileBlockLength - 1) / base.FileBlockLength
+
+	free := sm.freeSectors()
+	if need := count + dir.blocksNeeded(number); len(free) < need {
+		return fmt.Errorf("%w: need %d sectors, have %d",
+			base.ErrNoSpace, need, len(free))
+	}
+
+	for ix := 0; ix < count; ix++ {
+		end := (ix + 1) * base.FileBlockLength
+		if end > len(payload) {
+			end = len(payload)
+		}
+		if err := sm.setBlock(free[ix], number, ix,
+			payload[ix*base.FileBlockLength:end]); err != nil {
+			return fmt.Errorf("error writing block %d: %v", ix, err)
+		}
+	}
+
+	dir.setEntry(number, payload[:FileHeaderLength])
+	if _, err := dir.write(free[count:]); err != nil {
+		return err
+	}
+
+	return fs.finishUpdate(sm)
+}
+
+// Delete removes the file with given name from directory and sector map, and
+// marks its sectors as free.
+func (fs *fsys) Delete(name string) error {
+
+	if fs.cart.IsWriteProtected() {
+		return base.ErrWriteProtected
+	}
+
+	sm, dir, number, err := fs.lookup(name)
+	if err != nil {
+		return err
+	}
+
+	for s := 0; s < SectorCount; s++ {
+		if f, _ := sm.getSector(s); f == number {
+			if err := sm.setBlock(s, FileNumberFree, 0, nil); err != nil {
+				return fmt.Errorf("error freeing sector %d: %v", s, err)
+			}
+		}
+	}
+
+	dir.setEntry(number, nil)
+	if _, err := dir.write(nil); err != nil {
+		return err
+	}
+
+	return fs.finishUpdate(sm)
+}
+
+// Rename renames file oldName to newName, in both directory and file header.
+func (fs *fsys) Rename(oldName, newName string) error {
+
+	if fs.cart.IsWriteProtected() {
+		return base.ErrWriteProtected
+	}
+
+	if err := ValidateName(newName); err != nil {
+		return err
+	}
+
+	sm, dir, number, err := fs.lookup(oldName)
+	if err != nil {
+		return err
+	}
+
+	if n := dir.find(newName); n != -1 && n != number {
+		return fmt.Errorf("%w: %s", base.ErrFileExists, newName)
+	}
+
+	records, err := sm.collectFileRecords(number)
+	if err != nil {
+		return err
+	}
+	if len(records) == 0 || records[0] == nil {
+		return fmt.Errorf("first block of file %s missing", oldName)
+	}
+
+	first := records[0].(*record)
+	putName(first.Data(), newName)
+	if err := first.FixChecksums(); err != nil {
+		return err
+	}
+
+	entry := dir.entry(number)
+	putName(entry, newName)
+	dir.setEntry(number, entry)
+	if _, err := dir.write(nil); err != nil {
+		return err
+	}
+
+	return fs.finishUpdate(sm)
+}
+
+// lookup loads sector map and directory, and finds the number of the file
+// with given name
+func (fs *fsys) lookup(name string) (*sectorMap, *directory, int, error) {
+
+	sm, err := fs.sectorMap()
+	if err != nil {
+		return nil, nil, -1, err
+	}
+
+	dir, err := newDirectory(sm)
+	if err != nil {
+		return nil, nil, -1, err
+	}
+
+	number := dir.find(name)
+	if number == -1 {
+		return nil, nil, -1, base.ErrFileNotFound
+	}
+
+	return sm, dir, number, nil
+}
+
+//
+func (fs *fsys) finishUpdate(sm *sectorMap) error {
+	if err := sm.record.FixChecksums(); err != nil {
+		return fmt.Errorf("error updating sector map: %v", err)
+	}
+	fs.cart.SetModified(true)
+	return nil
+}
+
+// newFileHeader creates a 64 byte QDOS file header
+func newFileHeader(name string, length int, p util.Params) []byte {
+
+	h := make([]byte, FileHeaderLength)
+	putLength(h, FileHeaderLength+length)
+
+	if typ, ok := p.GetInt("fileType"); ok {
+		h[5] = byte(typ)
+	}
+	if space, ok := p.GetInt("dataspace"); ok {
+		putLength(h[6:], space)
+	}
+
+	putName(h, name)
+
+	date, ok := p.GetInt("dateUpdate")
+	if !ok {
+		date = int(time.Now().Unix())
+	}
+	putLength(h[52:], date+DateOffset)
+
+	return h
+}
+
+// putLength writes a 32 bit big endian value to the start of b
+func putLength(b []byte, l int) {
+	b[0] = byte(l >> 24)
+	b[1] = byte(l >> 16)
+	b[2] = byte(l >> 8)
+	b[3] = byte(l)
+}
+
+// putName writes name into the name field of file header h
+func putName(h []byte, name string) {
+	h[14] = byte(len(name) >> 8)
+	h[15] = byte(len(name))
+	n := copy(h[16:52], name)
+	for ix := 16 + n; ix < 52; ix++ {
+		h[ix] = 0
+	}
+}
+
+//
+func entryLength(e []byte) int {
+	return int(e[0])<<24 | int(e[1])<<16 | int(e[2])<<8 | int(e[3])
+}
+
+//
+func entryName(e []byte) string {
+	l := int(e[14])<<8 | int(e[15])
+	if l > 36 {
+		l = 36
 	}
+	return string(e[16 : 16+l])
+}
+
+// ValidateName checks whether name is a valid file name
+func ValidateName(name string) error {
 
-	return base.NewFsStats(fs.cart.SectorCount(), used), ret, nil
+	if name == "" || len(name) > 36 {
+		return fmt.Errorf(
+			"%w: needs to have 1 through 36 characters", base.ErrInvalidName)
+	}
+
+	for _, c := range name {
+		if c < '\x20' || c > '\x7e' {
+			return fmt.Errorf("%w: invalid character %q", base.ErrInvalidName, c)
+		}
+	}
+
+	return nil
 }
 
 //
@@ -151,12 +456,14 @@ func newSectorMap(cart *base.Cartridge, s base.Sector,
 	if sectors[0] != 0xf8 && sectors[1] != 0x00 {
 		return nil, fmt.Errorf("not a sector map")
 	}
-	return &sectorMap{cart: cart, sectors: sectors, index: index}, nil
+	return &sectorMap{cart: cart, record: s.Record().(*record),
+		sectors: sectors, index: index}, nil
 }
 
 //
 type sectorMap struct {
 	cart    *base.Cartridge
+	record  *record
 	sectors []byte
 	index   map[int]int
 }
@@ -169,6 +476,68 @@ func (sm *sectorMap) getSector(ix int) (int, int) {
 	return -1, -1
 }
 
+//
+func (sm *sectorMap) setSector(ix, file, block int) {
+	if 0 <= ix && 2*ix < len(sm.sectors) {
+		sm.sectors[ix*2] = byte(file)
+		sm.sectors[ix*2+1] = byte(block)
+	}
+}
+
+// isUsed determines whether any sector is allocated to given file number
+func (sm *sectorMap) isUsed(number int) bool {
+	for s := 0; s < SectorCount; s++ {
+		if f, _ := sm.getSector(s); f == number {
+			return true
+		}
+	}
+	return false
+}
+
+// freeSectors returns the numbers of all free sectors that are present on the
+// cartridge and hold a record, in descending order, i.e. the order in which
+// they pass by the drive head.
+func (sm *sectorMap) freeSectors() []int {
+
+	var ret []int
+
+	for s := SectorCount - 1; s >= 0; s-- {
+		if f, _ := sm.getSector(s); f != FileNumberFree {
+			continue
+		}
+		if ix, ok := sm.index[s]; ok {
+			if r, ok := sm.cart.GetSectorAt(ix).Record().(*record); ok && r != nil {
+				ret = append(ret, s)
+			}
+		}
+	}
+
+	return ret
+}
+
+// setBlock writes data as block number block of file number file into the
+// record of sector number s, and updates the sector map accordingly; the
+// sector map record's checksums need to be fixed separately
+func (sm *sectorMap) setBlock(s, file, block int, data []byte) error {
+
+	ix, ok := sm.index[s]
+	if !ok {
+		return fmt.Errorf("sector not found: %d", s)
+	}
+
+	r, ok := sm.cart.GetSectorAt(ix).Record().(*record)
+	if !ok || r == nil {
+		return fmt.Errorf("no record in sector: %d", s)
+	}
+
+	if err := r.setBlock(file, block, data); err != nil {
+		return err
+	}
+
+	sm.setSector(s, file, block)
+	return nil
+}
+
 //
 func (sm *sectorMap) collectFileRecords(number int) ([]base.Record, error) {
 
@@ -194,3 +563,36 @@ func (sm *sectorMap) collectFileRecords(number int) ([]base.Record, error) {
 
 	return records, nil
 }
+
+// FileParams returns the parameters for Create that reproduce the file header
+// contained in record r, which needs to be the first record of a file
+func FileParams(r base.Record) util.Params {
+	p := util.Params{}
+	if rec, ok := r.(*record); ok {
+		p["fileType"] = int(rec.block.GetByte("fileType"))
+		p["dataspace"] = rec.dataspace()
+		p["dateUpdate"] = rec.getInt32("dateUpdate") - DateOffset
+	}
+	return p
+}
+
+// UnwrapFile checks whether data starts with a QDOS file header whose length
+// matches the data, as is the case for files exported with header wrapper. If
+// so, the file data following the header is returned, together with the
+// parameters for Create that reproduce the header.
+func UnwrapFile(data []byte) ([]byte, util.Params, bool) {
+
+	if len(data) < FileHeaderLength || entryLength(data) != len(data) {
+		return nil, nil, false
+	}
+
+	if l := int(data[14])<<8 | int(data[15]); l > 36 {
+		return nil, nil, false
+	}
+
+	return data[FileHeaderLength:], util.Params{
+		"fileType":   int(data[5]),
+		"dataspace":  entryLength(data[6:]),
+		"dateUpdate": entryLength(data[52:]) - DateOffset,
+	}, true
+}
@@ -41,6 +41,7 @@ var recordIndex = map[string][2]int{
 	"accessKey":         {28, 1},
 	"fileType":          {29, 1},
 	"fileInfo":          {30, 8},
+	"dataspace":         {30, 4},
 	"name":              {38, 38}, // 2 bytes for length + name chars
 	"dateUpdate":        {76, 4},
 	"dateReference":     {80, 4},
@@ -75,6 +76,17 @@ func NewRecord(data []byte, isRaw bool) (*record, error) {
 	return r, r.Validate()
 }
 
+// newBlankRecord creates a free record, with all data bytes set to zero
+func newBlankRecord() (*record, error) {
+	r := &record{
+		block: raw.NewBlock(recordIndex,
+			make([]byte, RecordLength+FormatExtraBytes)),
+	}
+	raw.CopySyncPattern(r.block.Data)
+	raw.CopyDataSyncPattern(r.block.Data[16:])
+	return r, r.setBlock(FileNumberFree, 0, nil)
+}
+
 //
 func (r *record) Client() client.Client {
 	return client.QL
@@ -90,6 +102,11 @@ func (r *record) Demuxed() []byte {
 	return r.block.Data
 }
 
+//
+func (r *record) Block() *raw.Block {
+	return r.block
+}
+
 //
 func (r *record) mux() {
 	r.muxed = raw.Mux(r.block.Data, true)
@@ -107,7 +124,18 @@ func (r *record) Index() int {
 
 //
 func (r *record) Length() int {
-	l := r.block.GetSlice("length")
+	return r.getInt32("length")
+}
+
+// dataspace returns the data space of an executable file, as given in the file
+// header; only applicable to the first record of a file
+func (r *record) dataspace() int {
+	return r.getInt32("dataspace")
+}
+
+//
+func (r *record) getInt32(key string) int {
+	l := r.block.GetSlice(key)
 	if len(l) != 4 {
 		return -1
 	}
@@ -126,6 +154,14 @@ func (r *record) Name() string {
 	return ""
 }
 
+// isScreen determines from the file header contained in this record whether
+// the file is a screen dump, i.e. a data file with the size of the display
+// memory. Only meaningful for the first record of a file.
+func (r *record) isScreen() bool {
+	return r.Length()-FileHeaderLength == ScreenSize &&
+		r.block.GetByte("fileType") == 0
+}
+
 //
 func (r *record) HeaderChecksum() int {
 	return r.block.GetInt("headerChecksum")
@@ -251,3 +287,23 @@ func (r *record) Emit(w io.Writer) {
 	defer d.Close()
 	d.Write(r.block.Data)
 }
+
+// setBlock turns this record into block number block of file number file,
+// with given data; data is padded with zeros, or truncated to fit the record
+func (r *record) setBlock(file, block int, data []byte) error {
+
+	if err := r.block.SetByte("flags", byte(file)); err != nil {
+		return err
+	}
+	if err := r.block.SetByte("number", byte(block)); err != nil {
+		return err
+	}
+
+	d := r.Data()
+	n := copy(d, data)
+	for ix := n; ix < len(d); ix++ {
+		d[ix] = 0
+	}
+
+	return r.FixChecksums()
+}
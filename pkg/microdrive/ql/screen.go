@@ -0,0 +1,43 @@
+/*
+   OqtaDrive - Sinclair Microdrive emulator
+   Copyright (c) 2022, Alexander Vollschwitz
+
+   This file is part of OqtaDrive.
+
+   OqtaDrive is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   OqtaDrive is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with OqtaDrive. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package ql
+
+import (
+	"fmt"
+
+	"github.com/xelalexv/oqtadrive/pkg/microdrive/base"
+)
+
+// Screen returns the display memory contained in file f. f needs to be a data
+// file of 32768 bytes.
+func Screen(f *base.File) ([]byte, error) {
+
+	first, err := f.FirstRecord()
+	if err != nil {
+		return nil, err
+	}
+
+	if r, ok := first.(*record); !ok || !r.isScreen() {
+		return nil, fmt.Errorf("not a screen file")
+	}
+
+	return f.Bytes()
+}
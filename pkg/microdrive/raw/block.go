@@ -22,6 +22,7 @@ package raw
 
 import (
 	"fmt"
+	"sort"
 )
 
 //
@@ -50,6 +51,47 @@ func (b *Block) Length() int {
 	return len(b.Data)
 }
 
+// Fields returns the keys of all fields in the index of this block that do not
+// span other fields, ordered by offset. Together with the block data, these are
+// the fields needed for describing the block.
+func (b *Block) Fields() []string {
+
+	var ret []string
+
+	for k, ix := range b.index {
+		composite := false
+		for o, ox := range b.index {
+			if o != k && ix[0] <= ox[0] && ox[0]+ox[1] <= ix[0]+ix[1] &&
+				ox[1] < ix[1] {
+				composite = true
+				break
+			}
+		}
+		if !composite {
+			ret = append(ret, k)
+		}
+	}
+
+	sort.Slice(ret, func(i, j int) bool {
+		a, b := b.index[ret[i]], b.index[ret[j]]
+		if a[0] == b[0] {
+			return ret[i] < ret[j]
+		}
+		return a[0] < b[0]
+	})
+
+	return ret
+}
+
+// FieldLength returns the length of the field with given key, or 0 if there
+// is no such field in the index.
+func (b *Block) FieldLength(key string) int {
+	if ix, ok := b.index[key]; ok {
+		return ix[1]
+	}
+	return 0
+}
+
 //
 func (b *Block) GetByte(key string) byte {
 	if ix, ok := b.index[key]; ok {
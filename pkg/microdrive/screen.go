@@ -0,0 +1,61 @@
+/*
+   OqtaDrive - Sinclair Microdrive emulator
+   Copyright (c) 2022, Alexander Vollschwitz
+
+   This file is part of OqtaDrive.
+
+   OqtaDrive is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   OqtaDrive is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with OqtaDrive. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package microdrive
+
+import (
+	"fmt"
+
+	"github.com/xelalexv/oqtadrive/pkg/microdrive/base"
+	"github.com/xelalexv/oqtadrive/pkg/microdrive/client"
+	"github.com/xelalexv/oqtadrive/pkg/microdrive/if1"
+	"github.com/xelalexv/oqtadrive/pkg/microdrive/ql"
+	"github.com/xelalexv/oqtadrive/pkg/screen"
+)
+
+// ScreenPNG renders screen file f from a cartridge for the given client as PNG.
+// For IF1, flash selects the flash phase. For QL, mode is the screen mode, 4 or
+// 8. It defaults to 4 when passed as 0.
+func ScreenPNG(cl client.Client, f *base.File, flash bool,
+	mode int) ([]byte, error) {
+
+	switch cl {
+
+	case client.IF1:
+		data, err := if1.Screen(f)
+		if err != nil {
+			return nil, err
+		}
+		return screen.SpectrumPNG(data, flash)
+
+	case client.QL:
+		data, err := ql.Screen(f)
+		if err != nil {
+			return nil, err
+		}
+		if mode == 0 {
+			mode = 4
+		}
+		return screen.QLPNG(data, mode)
+
+	default:
+		return nil, fmt.Errorf("unsupported client type for screen: %d", cl)
+	}
+}
@@ -0,0 +1,93 @@
+/*
+   OqtaDrive - Sinclair Microdrive emulator
+   Copyright (c) 2022, Alexander Vollschwitz
+
+   This file is part of OqtaDrive.
+
+   OqtaDrive is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   OqtaDrive is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with OqtaDrive. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package repo
+
+import (
+	"bufio"
+	"io/ioutil"
+	"os"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/xelalexv/oqtadrive/pkg/microdrive/format"
+)
+
+// fingerprint returns the content fingerprint of the cartridge image in file,
+// or an empty string if file is not a cartridge image or cannot be read.
+// Snapshots and tape files are not considered, since they only turn into
+// cartridges when loading. Archives are considered if they contain a single
+// cartridge image.
+func fingerprint(file string) string {
+
+	_, typ, comp := format.SplitNameTypeCompressor(file)
+
+	if !isCartridgeType(typ) && !(typ == "" && format.IsArchive(comp)) {
+		return ""
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		log.Debugf("cannot open %s for fingerprint: %v", file, err)
+		return ""
+	}
+	defer f.Close()
+
+	rd, err := format.NewCartReader(ioutil.NopCloser(bufio.NewReader(f)), comp)
+	if err != nil {
+		log.Debugf("cannot read %s for fingerprint: %v", file, err)
+		return ""
+	}
+	defer rd.Close()
+
+	if typ == "" {
+		if typ = rd.Type(); !isCartridgeType(typ) || len(rd.Entries()) > 1 {
+			return ""
+		}
+	}
+
+	form, err := format.NewFormat(typ)
+	if err != nil {
+		return ""
+	}
+
+	cart, err := form.Read(rd, false, false, nil)
+	if err != nil {
+		log.Debugf("cannot read %s for fingerprint: %v", file, err)
+		return ""
+	}
+
+	fp, err := cart.Fingerprint()
+	if err != nil {
+		log.Debugf("cannot determine fingerprint of %s: %v", file, err)
+		return ""
+	}
+
+	return fp
+}
+
+//
+func isCartridgeType(typ string) bool {
+	switch typ {
+	case "mdr", "mdv", "json":
+		return true
+	}
+	return false
+}
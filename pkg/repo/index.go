@@ -62,7 +62,7 @@ func NewIndex(base, repo string) (*Index, error) {
 func createOrOpen(base, repo string) (*Index, error) {
 
 	var err error
-	i := &Index{}
+	i := &Index{pending: make(map[string]bool)}
 
 	if i.base, err = filepath.Abs(base); err != nil {
 		return nil, err
@@ -102,9 +102,11 @@ func createOrOpen(base, repo string) (*Index, error) {
 	return i, nil
 }
 
-//
+// Entry is an entry in the index; Fingerprint is the content fingerprint of
+// cartridge images, and empty for all other files
 type Entry struct {
-	Name string
+	Name        string
+	Fingerprint string
 }
 
 //
@@ -119,6 +121,7 @@ type Index struct {
 	//
 	batch      *bleve.Batch
 	batchCount int
+	pending    map[string]bool // files to add with next flush
 }
 
 //
@@ -255,16 +258,22 @@ func (i *Index) watchEvent(evt fsnotify.Event) error {
 
 	switch evt.Op {
 
+	// new and changed files are added when flushing, since cartridge images
+	// need to be completely written for determining their fingerprint
 	case fsnotify.Create:
 		if info, err := os.Stat(evt.Name); err != nil {
 			log.Errorf("cannot add new entry: %v", err)
 		} else if !info.IsDir() {
-			i.addEntry(rel)
+			i.pending[rel] = true
 		}
 
+	case fsnotify.Write:
+		i.pending[rel] = true
+
 	case fsnotify.Rename:
 		fallthrough
 	case fsnotify.Remove:
+		delete(i.pending, rel)
 		i.removeEntry(rel)
 
 	default:
@@ -276,6 +285,10 @@ func (i *Index) watchEvent(evt fsnotify.Event) error {
 
 //
 func (i *Index) flushEvent() error {
+	for path := range i.pending {
+		i.addEntry(path)
+		delete(i.pending, path)
+	}
 	return i.batched(true)
 }
 
@@ -285,8 +298,12 @@ func (i *Index) addEntry(path string) error {
 	logger := log.WithField("file", path)
 	logger.Debug("adding new entry to index")
 
-	if err := i.batch.Index(
-		path, Entry{Name: nameCleaner.Replace(path)}); err != nil {
+	entry := Entry{
+		Name:        nameCleaner.Replace(path),
+		Fingerprint: fingerprint(filepath.Join(i.repo, path)),
+	}
+
+	if err := i.batch.Index(path, entry); err != nil {
 		logger.Errorf("failed to batch entry add: %v", err)
 		return err
 	}
@@ -0,0 +1,92 @@
+/*
+   OqtaDrive - Sinclair Microdrive emulator
+   Copyright (c) 2022, Alexander Vollschwitz
+
+   This file is part of OqtaDrive.
+
+   OqtaDrive is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   OqtaDrive is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with OqtaDrive. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package run
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/xelalexv/oqtadrive/pkg/microdrive/base"
+	"github.com/xelalexv/oqtadrive/pkg/microdrive/format"
+)
+
+// readCartridge reads a cartridge from file. Format and compressor are
+// determined from the file name, or from the content if that is not possible.
+func readCartridge(file string, repair bool) (*base.Cartridge, error) {
+
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	_, typ, comp := format.SplitNameTypeCompressor(file)
+
+	rd, err := format.NewSniffingCartReader(
+		ioutil.NopCloser(bufio.NewReader(f)), comp, typ, "")
+	if err != nil {
+		return nil, err
+	}
+
+	form, err := format.NewFormat(rd.Type())
+	if err != nil {
+		return nil, err
+	}
+
+	return form.Read(rd, false, repair, nil)
+}
+
+// writeCartridge writes a cartridge to file. The format is determined from
+// the file name, and needs to be the native format of the cartridge's client,
+// or json.
+func writeCartridge(file string, cart *base.Cartridge) error {
+
+	_, typ, comp := format.SplitNameTypeCompressor(file)
+
+	if comp != "" {
+		return fmt.Errorf("compressed cartridge saving not supported")
+	}
+
+	if typ != cart.Client().DefaultFormat() && typ != "json" {
+		return fmt.Errorf("cannot save %s cartridge as '%s'",
+			cart.Client(), typ)
+	}
+
+	form, err := format.NewFormat(typ)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	out := bufio.NewWriter(f)
+	if err := form.Write(cart, out, nil); err != nil {
+		return err
+	}
+
+	return out.Flush()
+}
@@ -0,0 +1,116 @@
+/*
+   OqtaDrive - Sinclair Microdrive emulator
+   Copyright (c) 2022, Alexander Vollschwitz
+
+   This file is part of OqtaDrive.
+
+   OqtaDrive is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   OqtaDrive is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with OqtaDrive. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package run
+
+import (
+	"fmt"
+	"io/ioutil"
+)
+
+//
+func NewCopy() *Transfer {
+	return newTransfer("copy", "copy cartridge to another drive",
+		`
+Use the copy command to place a copy of the cartridge in one drive into another
+drive. The copy retains the modified state of the original cartridge.`)
+}
+
+//
+func NewMove() *Transfer {
+	return newTransfer("move", "move cartridge to another drive",
+		`
+Use the move command to move the cartridge in one drive to another drive. The
+source drive is left with a blank, unformatted cartridge. The cartridge retains
+its modified state.`)
+}
+
+//
+func NewSwap() *Transfer {
+	return newTransfer("swap", "swap cartridges between two drives",
+		`
+Use the swap command to swap the cartridges of two drives. The cartridges
+retain their modified state.`)
+}
+
+//
+func newTransfer(op, short, long string) *Transfer {
+
+	t := &Transfer{op: op}
+
+	use := fmt.Sprintf(
+		"%s -d|--drive {drive} -t|--to {drive} [-f|--force] [-a|--address {address}]",
+		op)
+	if op == "swap" {
+		use = "swap -d|--drive {drive} -t|--to {drive} [-a|--address {address}]"
+	}
+
+	t.Runner = *NewRunner(use, short, long, "", runnerHelpEpilogue, t.Run)
+
+	t.AddBaseSettings()
+	t.AddSetting(&t.Drive, "drive", "d", "", nil, "source drive number (1-8)", true)
+	t.AddSetting(&t.To, "to", "t", "", nil, "target drive number (1-8)", true)
+	if op != "swap" {
+		t.AddSetting(&t.Force, "force", "f", "", false,
+			"force replacing modified cartridge in target drive", false)
+	}
+
+	return t
+}
+
+// Transfer is the runner for the copy, move, and swap commands
+type Transfer struct {
+	//
+	Runner
+	//
+	Drive int
+	To    int
+	Force bool
+	//
+	op string
+}
+
+//
+func (t *Transfer) Run() error {
+
+	t.ParseSettings()
+
+	if err := validateDrive(t.Drive); err != nil {
+		return err
+	}
+	if err := validateDrive(t.To); err != nil {
+		return err
+	}
+
+	resp, err := t.apiCall("POST", fmt.Sprintf("/drive/%d/%s?to=%d&force=%v",
+		t.Drive, t.op, t.To, t.Force), false, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Close()
+
+	msg, err := ioutil.ReadAll(resp)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%s", msg)
+	return nil
+}
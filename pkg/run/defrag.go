@@ -0,0 +1,121 @@
+/*
+   OqtaDrive - Sinclair Microdrive emulator
+   Copyright (c) 2022, Alexander Vollschwitz
+
+   This file is part of OqtaDrive.
+
+   OqtaDrive is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   OqtaDrive is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with OqtaDrive. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package run
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/xelalexv/oqtadrive/pkg/microdrive"
+)
+
+//
+func NewDefrag() *Defrag {
+
+	d := &Defrag{}
+	d.Runner = *NewRunner(
+		`defrag [-d|--drive {drive}] [-i|--input {cartridge file}] [-j|--json]
+    [-a|--address {address}]`,
+		"defragment cartridge",
+		`
+Use the defrag command to rewrite all files on a cartridge in the daemon, or in
+a cartridge file, into contiguous sectors, in the order in which sectors pass by
+the drive head. This reduces the number of cartridge revolutions needed for
+loading a file. A cartridge file is modified in place.`,
+		"", `- Defragmenting is only supported for Spectrum cartridges.
+
+- For each file, estimated revolutions needed for loading it are reported, both
+  before and after defragmenting.
+
+- Only uncompressed cartridge files in the native format of their client,
+  i.e. .mdr or .mdv, can be modified.
+
+`+runnerHelpEpilogue, d.Run)
+
+	d.AddBaseSettings()
+	d.AddSetting(&d.Drive, "drive", "d", "", 1, "drive number (1-8)", false)
+	d.AddSetting(&d.Input, "input", "i", "", nil, "cartridge file", false)
+	d.AddSetting(&d.JSON, "json", "j", "", false, "output report as JSON", false)
+
+	return d
+}
+
+//
+type Defrag struct {
+	//
+	Runner
+	//
+	Drive int
+	Input string
+	JSON  bool
+}
+
+//
+func (d *Defrag) Run() error {
+
+	d.ParseSettings()
+
+	if d.Input != "" {
+		cart, err := readCartridge(d.Input, false)
+		if err != nil {
+			return err
+		}
+		report, err := microdrive.Defrag(cart)
+		if err != nil {
+			return err
+		}
+		if report.Moved > 0 {
+			if err := writeCartridge(d.Input, cart); err != nil {
+				return err
+			}
+		}
+		if d.JSON {
+			out, err := json.MarshalIndent(report, "", "  ")
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(out))
+		} else {
+			fmt.Println(report)
+		}
+		return nil
+	}
+
+	if err := validateDrive(d.Drive); err != nil {
+		return err
+	}
+
+	resp, err := d.apiCall("PUT", fmt.Sprintf("/drive/%d/defrag", d.Drive),
+		d.JSON, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Close()
+
+	msg, err := ioutil.ReadAll(resp)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%s", msg)
+	return nil
+}
@@ -0,0 +1,101 @@
+/*
+   OqtaDrive - Sinclair Microdrive emulator
+   Copyright (c) 2022, Alexander Vollschwitz
+
+   This file is part of OqtaDrive.
+
+   OqtaDrive is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   OqtaDrive is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with OqtaDrive. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package run
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/url"
+)
+
+//
+func NewDelete() *Delete {
+
+	d := &Delete{}
+	d.Runner = *NewRunner(
+		`delete [-d|--drive {drive}] [-i|--input {cartridge file}] -f|--file {name}
+    [-a|--address {address}]`,
+		"delete file from cartridge",
+		`
+Use the delete command to remove a file from a cartridge in the daemon, or from
+a cartridge file. A cartridge file is modified in place.`,
+		"", `- Only uncompressed cartridge files in the native format of their client,
+  i.e. .mdr or .mdv, can be modified.
+
+`+runnerHelpEpilogue, d.Run)
+
+	d.AddBaseSettings()
+	d.AddSetting(&d.Drive, "drive", "d", "", 1, "drive number (1-8)", false)
+	d.AddSetting(&d.Input, "input", "i", "", nil, "cartridge file", false)
+	d.AddSetting(&d.File, "file", "f", "", nil, "file to delete", true)
+
+	return d
+}
+
+//
+type Delete struct {
+	//
+	Runner
+	//
+	Drive int
+	Input string
+	File  string
+}
+
+//
+func (d *Delete) Run() error {
+
+	d.ParseSettings()
+
+	if d.Input != "" {
+		cart, err := readCartridge(d.Input, false)
+		if err != nil {
+			return err
+		}
+		if err := cart.FS().Delete(d.File); err != nil {
+			return err
+		}
+		if err := writeCartridge(d.Input, cart); err != nil {
+			return err
+		}
+		fmt.Printf("deleted %s from cartridge\n", d.File)
+		return nil
+	}
+
+	if err := validateDrive(d.Drive); err != nil {
+		return err
+	}
+
+	resp, err := d.apiCall("DELETE", fmt.Sprintf("/drive/%d/file/%s",
+		d.Drive, url.PathEscape(d.File)), false, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Close()
+
+	msg, err := ioutil.ReadAll(resp)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%s", msg)
+	return nil
+}
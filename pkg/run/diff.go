@@ -0,0 +1,182 @@
+/*
+   OqtaDrive - Sinclair Microdrive emulator
+   Copyright (c) 2022, Alexander Vollschwitz
+
+   This file is part of OqtaDrive.
+
+   OqtaDrive is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   OqtaDrive is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with OqtaDrive. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package run
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+
+	"github.com/xelalexv/oqtadrive/pkg/microdrive/base"
+	"github.com/xelalexv/oqtadrive/pkg/microdrive/format"
+)
+
+//
+func NewDiff() *Diff {
+
+	d := &Diff{}
+	d.Runner = *NewRunner(
+		`diff -l|--left {drive|cartridge file} -r|--right {drive|cartridge file}
+    [-s|--sectors] [-j|--json] [-a|--address {address}]`,
+		"compare two cartridges",
+		`
+Use the diff command to compare two cartridges. Each side of the comparison can
+be a drive in the daemon, given by its number, or a cartridge file. Files added,
+removed, or changed when going from left to right are reported.`,
+		"", `- A file is changed when its size, its type or other file attributes, or its
+  content differ.
+
+- With --sectors, headers and records of all sectors are compared as well.
+  Sectors are matched by their sector number, not by their position on the
+  cartridge.
+
+`+runnerHelpEpilogue, d.Run)
+
+	d.AddBaseSettings()
+	d.AddSetting(&d.Left, "left", "l", "", nil,
+		"left side of comparison, drive number (1-8) or cartridge file", true)
+	d.AddSetting(&d.Right, "right", "r", "", nil,
+		"right side of comparison, drive number (1-8) or cartridge file", true)
+	d.AddSetting(&d.Sectors, "sectors", "s", "", false,
+		"compare at sector level as well", false)
+	d.AddSetting(&d.JSON, "json", "j", "", false, "output report as JSON", false)
+
+	return d
+}
+
+//
+type Diff struct {
+	//
+	Runner
+	//
+	Left    string
+	Right   string
+	Sectors bool
+	JSON    bool
+}
+
+//
+func (d *Diff) Run() error {
+
+	d.ParseSettings()
+
+	leftDrive, err := asDrive(d.Left)
+	if err != nil {
+		return err
+	}
+	rightDrive, err := asDrive(d.Right)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case leftDrive == -1 && rightDrive == -1:
+		return d.diffFiles()
+	case leftDrive > 0 && rightDrive > 0:
+		return d.showAPIReport("GET", fmt.Sprintf("/drive/%d/diff?with=%d",
+			leftDrive, rightDrive), "")
+	case leftDrive > 0:
+		return d.showAPIReport("PUT", fmt.Sprintf("/drive/%d/diff?reverse=false",
+			leftDrive), d.Right)
+	default:
+		return d.showAPIReport("PUT", fmt.Sprintf("/drive/%d/diff?reverse=true",
+			rightDrive), d.Left)
+	}
+}
+
+//
+func (d *Diff) diffFiles() error {
+
+	left, err := readCartridge(d.Left, false)
+	if err != nil {
+		return err
+	}
+	right, err := readCartridge(d.Right, false)
+	if err != nil {
+		return err
+	}
+
+	report, err := base.Diff(left, right, d.Sectors)
+	if err != nil {
+		return err
+	}
+
+	if d.JSON {
+		out, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(out))
+	} else {
+		fmt.Println(report)
+	}
+	return nil
+}
+
+// showAPIReport retrieves a diff report from the daemon and prints it. If
+// file is not empty, it is uploaded as the cartridge to compare against.
+func (d *Diff) showAPIReport(method, path, file string) error {
+
+	path += fmt.Sprintf("&sectors=%v", d.Sectors)
+
+	var body io.Reader
+	if file != "" {
+		f, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, typ, comp := format.SplitNameTypeCompressor(file)
+		path += fmt.Sprintf("&type=%s&compressor=%s", typ, comp)
+		body = bufio.NewReader(f)
+	}
+
+	resp, err := d.apiCall(method, path, d.JSON, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Close()
+
+	msg, err := ioutil.ReadAll(resp)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%s", msg)
+	return nil
+}
+
+// asDrive returns the drive number if s denotes a drive, or -1 if s is a file.
+// A number that is the name of an existing file is taken as a file.
+func asDrive(s string) (int, error) {
+	d, err := strconv.Atoi(s)
+	if err != nil {
+		return -1, nil
+	}
+	if _, err := os.Stat(s); err == nil {
+		return -1, nil
+	}
+	return d, validateDrive(d)
+}
@@ -21,14 +21,11 @@
 package run
 
 import (
-	"bufio"
 	"encoding/hex"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
-
-	"github.com/xelalexv/oqtadrive/pkg/microdrive/format"
 )
 
 //
@@ -65,29 +62,7 @@ func (d *Dump) Run() error {
 	d.ParseSettings()
 
 	if d.Input != "" {
-		f, err := os.Open(d.Input)
-		if err != nil {
-			return err
-		}
-		defer f.Close()
-
-		_, typ, comp := format.SplitNameTypeCompressor(d.Input)
-
-		rd, err := format.NewCartReader(ioutil.NopCloser(bufio.NewReader(f)), comp)
-		if err != nil {
-			return err
-		}
-
-		if typ == "" {
-			typ = rd.Type()
-		}
-
-		form, err := format.NewFormat(typ)
-		if err != nil {
-			return err
-		}
-
-		cart, err := form.Read(rd, false, false, nil)
+		cart, err := readCartridge(d.Input, false)
 		if err != nil {
 			return err
 		}
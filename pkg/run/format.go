@@ -0,0 +1,136 @@
+/*
+   OqtaDrive - Sinclair Microdrive emulator
+   Copyright (c) 2022, Alexander Vollschwitz
+
+   This file is part of OqtaDrive.
+
+   OqtaDrive is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   OqtaDrive is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with OqtaDrive. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package run
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"strconv"
+
+	"github.com/xelalexv/oqtadrive/pkg/microdrive"
+	"github.com/xelalexv/oqtadrive/pkg/microdrive/client"
+	"github.com/xelalexv/oqtadrive/pkg/microdrive/format"
+)
+
+//
+func NewFormat() *Format {
+
+	f := &Format{}
+	f.Runner = *NewRunner(
+		`format [-d|--drive {drive}] [-o|--output {cartridge file}] -n|--name {name}
+    [--sectors {count}] [-c|--client {if1|ql}] [-f|--force] [-a|--address {address}]`,
+		"format cartridge",
+		`
+Use the format command to format the cartridge in a drive of the daemon, or to
+create a formatted cartridge file. Any cartridge present in the drive is replaced.`,
+		"", `- The number of sectors defaults to the maximum possible, i.e. 254 for
+  Spectrum and 255 for QL cartridges. Real cartridges usually hold less.
+
+- When formatting a drive, the client defaults to the one currently connected
+  to the daemon. When creating a cartridge file, it is determined from the
+  file extension, i.e. .mdr or .mdv.
+
+`+runnerHelpEpilogue, f.Run)
+
+	f.AddBaseSettings()
+	f.AddSetting(&f.Drive, "drive", "d", "", 1, "drive number (1-8)", false)
+	f.AddSetting(&f.Output, "output", "o", "", nil, "cartridge output file", false)
+	f.AddSetting(&f.Name, "name", "n", "", nil, "cartridge name", true)
+	f.AddSetting(&f.Sectors, "sectors", "", "", 0, "number of sectors", false)
+	f.AddSetting(&f.Client, "client", "c", "", nil,
+		"client type, if1 or ql", false)
+	f.AddSetting(&f.Force, "force", "f", "", false,
+		"force replacing modified cartridge in daemon", false)
+
+	return f
+}
+
+//
+type Format struct {
+	//
+	Runner
+	//
+	Drive   int
+	Output  string
+	Name    string
+	Sectors int
+	Client  string
+	Force   bool
+}
+
+//
+func (f *Format) Run() error {
+
+	f.ParseSettings()
+
+	if f.Output != "" {
+
+		cl := client.GetClient(f.Client)
+		if cl == client.UNKNOWN {
+			switch _, typ, _ := format.SplitNameTypeCompressor(f.Output); typ {
+			case client.IF1.DefaultFormat():
+				cl = client.IF1
+			case client.QL.DefaultFormat():
+				cl = client.QL
+			default:
+				return fmt.Errorf("cannot determine client type for '%s'", typ)
+			}
+		}
+
+		if f.Sectors == 0 {
+			f.Sectors = microdrive.MaxSectorCount(cl)
+		}
+
+		cart, err := microdrive.NewFormattedCartridge(cl, f.Name, f.Sectors)
+		if err != nil {
+			return err
+		}
+
+		if err := writeCartridge(f.Output, cart); err != nil {
+			return err
+		}
+
+		fmt.Printf("formatted cartridge with %d sectors\n", f.Sectors)
+		return nil
+	}
+
+	if err := validateDrive(f.Drive); err != nil {
+		return err
+	}
+
+	resp, err := f.apiCall("PUT", fmt.Sprintf(
+		"/drive/%d/format?name=%s&sectors=%d&client=%s&force=%s",
+		f.Drive, url.QueryEscape(f.Name), f.Sectors, url.QueryEscape(f.Client),
+		strconv.FormatBool(f.Force)), false, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Close()
+
+	msg, err := ioutil.ReadAll(resp)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%s", msg)
+	return nil
+}
@@ -0,0 +1,132 @@
+/*
+   OqtaDrive - Sinclair Microdrive emulator
+   Copyright (c) 2022, Alexander Vollschwitz
+
+   This file is part of OqtaDrive.
+
+   OqtaDrive is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   OqtaDrive is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with OqtaDrive. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package run
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+)
+
+//
+func NewFsck() *Fsck {
+
+	f := &Fsck{}
+	f.Runner = *NewRunner(
+		`fsck [-d|--drive {drive}] [-i|--input {cartridge file}] [-r|--repair]
+    [-j|--json] [-a|--address {address}]`,
+		"check cartridge consistency",
+		`
+Use the fsck command to check the logical consistency of a cartridge in the
+daemon, or of a cartridge file. Problems found are reported, and optionally
+repaired where this is safe. A cartridge file is modified in place.`,
+		"", `- Checks for Spectrum cartridges cover missing or duplicate records, records
+  beyond the end of a file, record lengths that disagree with the file header,
+  and sector headers with deviating cartridge name.
+
+- Checks for QL cartridges cover missing or duplicate zero blocks, sector map
+  entries pointing at missing or free sectors, orphaned blocks, missing or
+  duplicate blocks, directory entries disagreeing with file headers, and sector
+  headers with deviating cartridge name.
+
+- With --repair, deviating cartridge names are corrected, duplicate records
+  with identical content are removed, and orphaned QL blocks are freed. Other
+  problems are only reported.
+
+- Only uncompressed cartridge files in the native format of their client,
+  i.e. .mdr or .mdv, can be repaired.
+
+`+runnerHelpEpilogue, f.Run)
+
+	f.AddBaseSettings()
+	f.AddSetting(&f.Drive, "drive", "d", "", 1, "drive number (1-8)", false)
+	f.AddSetting(&f.Input, "input", "i", "", nil, "cartridge file", false)
+	f.AddSetting(&f.Repair, "repair", "r", "", false,
+		"repair problems where safe", false)
+	f.AddSetting(&f.JSON, "json", "j", "", false, "output report as JSON", false)
+
+	return f
+}
+
+//
+type Fsck struct {
+	//
+	Runner
+	//
+	Drive  int
+	Input  string
+	Repair bool
+	JSON   bool
+}
+
+//
+func (f *Fsck) Run() error {
+
+	f.ParseSettings()
+
+	if f.Input != "" {
+		cart, err := readCartridge(f.Input, false)
+		if err != nil {
+			return err
+		}
+		if !cart.IsFormatted() {
+			return fmt.Errorf("cartridge is not formatted")
+		}
+		report, err := cart.FS().Check(f.Repair)
+		if err != nil {
+			return err
+		}
+		if report.Repaired() > 0 {
+			if err := writeCartridge(f.Input, cart); err != nil {
+				return err
+			}
+		}
+		if f.JSON {
+			out, err := json.MarshalIndent(report, "", "  ")
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(out))
+		} else {
+			fmt.Println(report)
+		}
+		return nil
+	}
+
+	if err := validateDrive(f.Drive); err != nil {
+		return err
+	}
+
+	resp, err := f.apiCall("GET", fmt.Sprintf("/drive/%d/check?repair=%v",
+		f.Drive, f.Repair), f.JSON, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Close()
+
+	msg, err := ioutil.ReadAll(resp)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%s", msg)
+	return nil
+}
@@ -0,0 +1,141 @@
+/*
+   OqtaDrive - Sinclair Microdrive emulator
+   Copyright (c) 2022, Alexander Vollschwitz
+
+   This file is part of OqtaDrive.
+
+   OqtaDrive is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   OqtaDrive is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with OqtaDrive. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package run
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+
+	"github.com/xelalexv/oqtadrive/pkg/microdrive"
+)
+
+//
+func NewGet() *Get {
+
+	g := &Get{}
+	g.Runner = *NewRunner(
+		`get [-d|--drive {drive}] [-i|--input {cartridge file}] -f|--file {name}
+    -o|--output {file} [-w|--wrapper {raw|header}] [--png [--flash] [--mode {4|8}]]
+    [-a|--address {address}]`,
+		"get file from cartridge",
+		`
+Use the get command to extract a file from a cartridge in the daemon, or from
+a cartridge file, and store it in the local file system.`,
+		"", `- The wrapper determines what gets stored. With raw (default), only the file
+  data is stored. With header, the file data is preceded by the file header,
+  i.e. the 9 byte header for Spectrum files, or the 64 byte QDOS header for QL
+  files.
+
+- With --png, a screen file is rendered and stored as a PNG image. For the
+  Spectrum, this is a code file of 6912 bytes at address 16384. Use --flash to
+  render the flash phase in which ink and paper of flashing cells are swapped.
+  For the QL, this is a data file of 32768 bytes. Use --mode to select screen
+  mode 4 (512x256, four colours, default) or 8 (256x256, eight colours).
+
+`+runnerHelpEpilogue, g.Run)
+
+	g.AddBaseSettings()
+	g.AddSetting(&g.Drive, "drive", "d", "", 1, "drive number (1-8)", false)
+	g.AddSetting(&g.Input, "input", "i", "", nil, "cartridge file", false)
+	g.AddSetting(&g.File, "file", "f", "", nil, "file to get", true)
+	g.AddSetting(&g.Output, "output", "o", "", nil, "output file", true)
+	g.AddSetting(&g.Wrapper, "wrapper", "w", "", "raw",
+		"output wrapper, raw or header", false)
+	g.AddSetting(&g.PNG, "png", "", "", false, "render screen file as PNG", false)
+	g.AddSetting(&g.Flash, "flash", "", "", false,
+		"render flash phase of Spectrum screen", false)
+	g.AddSetting(&g.Mode, "mode", "", "", 4, "QL screen mode, 4 or 8", false)
+
+	return g
+}
+
+//
+type Get struct {
+	//
+	Runner
+	//
+	Drive   int
+	Input   string
+	File    string
+	Output  string
+	Wrapper string
+	PNG     bool
+	Flash   bool
+	Mode    int
+}
+
+//
+func (g *Get) Run() error {
+
+	g.ParseSettings()
+
+	var data []byte
+
+	if g.Input != "" {
+		cart, err := readCartridge(g.Input, false)
+		if err != nil {
+			return err
+		}
+		f, err := cart.FS().Open(g.File)
+		if err != nil {
+			return err
+		}
+		if g.PNG {
+			data, err = microdrive.ScreenPNG(cart.Client(), f, g.Flash, g.Mode)
+		} else {
+			data, err = f.Wrapped(g.Wrapper)
+		}
+		if err != nil {
+			return err
+		}
+
+	} else {
+		if err := validateDrive(g.Drive); err != nil {
+			return err
+		}
+
+		path := fmt.Sprintf("/drive/%d/file/%s?wrapper=%s",
+			g.Drive, url.PathEscape(g.File), url.QueryEscape(g.Wrapper))
+		if g.PNG {
+			path = fmt.Sprintf("/drive/%d/file/%s/screen.png?flash=%v&mode=%d",
+				g.Drive, url.PathEscape(g.File), g.Flash, g.Mode)
+		}
+
+		resp, err := g.apiCall("GET", path, false, nil)
+		if err != nil {
+			return err
+		}
+		defer resp.Close()
+
+		if data, err = ioutil.ReadAll(resp); err != nil {
+			return err
+		}
+	}
+
+	if err := os.WriteFile(g.Output, data, 0644); err != nil {
+		return err
+	}
+
+	fmt.Printf("got %s from cartridge, %d bytes\n", g.File, len(data))
+	return nil
+}
@@ -21,14 +21,12 @@
 package run
 
 import (
-	"bufio"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
 
 	"github.com/xelalexv/oqtadrive/pkg/control"
-	"github.com/xelalexv/oqtadrive/pkg/microdrive/format"
 )
 
 //
@@ -64,29 +62,7 @@ func (l *List) Run() error {
 	l.ParseSettings()
 
 	if l.File != "" {
-		f, err := os.Open(l.File)
-		if err != nil {
-			return err
-		}
-		defer f.Close()
-
-		_, typ, comp := format.SplitNameTypeCompressor(l.File)
-
-		rd, err := format.NewCartReader(ioutil.NopCloser(bufio.NewReader(f)), comp)
-		if err != nil {
-			return err
-		}
-
-		if typ == "" {
-			typ = rd.Type()
-		}
-
-		form, err := format.NewFormat(typ)
-		if err != nil {
-			return err
-		}
-
-		cart, err := form.Read(rd, false, false, nil)
+		cart, err := readCartridge(l.File, false)
 		if err != nil {
 			return err
 		}
@@ -0,0 +1,107 @@
+/*
+   OqtaDrive - Sinclair Microdrive emulator
+   Copyright (c) 2022, Alexander Vollschwitz
+
+   This file is part of OqtaDrive.
+
+   OqtaDrive is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   OqtaDrive is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with OqtaDrive. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package run
+import (
+	"fmt"
+	"io/ioutil"
+	"net/url"
+
+	"github.com/xelalexv/oqtadrive/pkg/microdrive/client"
+	"github.com/xelalexv/oqtadrive/pkg/microdrive/if1"
+)
+
+//
+func NewListBasic() *ListBasic {
+
+	l := &ListBasic{}
+	l.Runner = *NewRunner(
+		`list-basic [-d|--drive {drive}] [-i|--input {cartridge file}] -f|--file {name}`,
+		"list BASIC program",
+		`
+Use the list-basic command to show the listing of a Spectrum BASIC program
+located on a cartridge in the daemon, or in a cartridge file.`,
+		"", `- Embedded colour and position control codes are shown as {INK 2}, {AT 0,5},
+  etc. Variables saved along with the program are listed after the program.
+
+`+runnerHelpEpilogue, l.Run)
+
+	l.AddBaseSettings()
+	l.AddSetting(&l.Drive, "drive", "d", "", 1, "drive number (1-8)", false)
+	l.AddSetting(&l.Input, "input", "i", "", nil, "cartridge file", false)
+	l.AddSetting(&l.File, "file", "f", "", nil, "program file to list", true)
+
+	return l
+}
+
+//
+type ListBasic struct {
+	//
+	Runner
+	//
+	Drive int
+	Input string
+	File  string
+}
+
+//
+func (l *ListBasic) Run() error {
+
+	l.ParseSettings()
+
+	if l.Input != "" {
+		cart, err := readCartridge(l.Input, false)
+		if err != nil {
+			return err
+		}
+		if cart.Client() != client.IF1 {
+			return fmt.Errorf("listings only supported for IF1 cartridges")
+		}
+		f, err := cart.FS().Open(l.File)
+		if err != nil {
+			return err
+		}
+		listing, err := if1.Listing(f)
+		if err != nil {
+			return err
+		}
+		fmt.Print(listing)
+		return nil
+	}
+
+	if err := validateDrive(l.Drive); err != nil {
+		return err
+	}
+
+	resp, err := l.apiCall("GET", fmt.Sprintf("/drive/%d/file/%s/listing",
+		l.Drive, url.PathEscape(l.File)), false, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Close()
+
+	listing, err := ioutil.ReadAll(resp)
+	if err != nil {
+		return err
+	}
+
+	fmt.Print(string(listing))
+	return nil
+}
@@ -39,13 +39,33 @@ func NewLoad() *Load {
 	l := &Load{}
 	l.Runner = *NewRunner(
 		`load [-d|--drive {drive}] -i|--input {file|reference} [-f|--force] [-r|--repair]
-       [-a|--address {address}] [-n|--name {cartridge name}] [-l|--launcher {type}]`,
+       [-a|--address {address}] [-n|--name {cartridge name}] [-l|--launcher {type}]
+       [--loader] [-e|--entry {archive entry}] [--all]`,
 		"load cartridge into daemon",
 		"\nUse the load command to load a cartridge into the daemon.",
 		"", `- You can directly load Z80 snapshot files into the daemon. The type of launcher
   placed onto the created cartridge can be changed with the --launcher setting.
   'hidden' (default) is a newer launcher that fixes screen corruption problems.
 
+- TAP and TZX files are converted into cartridges, with each file on tape
+  becoming a file on the cartridge. With --loader, the first BASIC program is
+  stored as 'run', with its LOAD "" statements changed to load from microdrive
+  instead. Blocks without header, and TZX blocks that do not hold regular tape
+  data, cannot be converted.
+
+- Format and compression are determined from the file extension. If that is not
+  possible, e.g. for a file named game.bin or a reference with query string,
+  they are determined from the content instead. This works for all formats
+  except TAP.
+
+- For zip, 7z, and tar archives with more than one entry, the entry to load can
+  be selected with --entry, either by its full path within the archive, or just
+  its file name. Without --entry, the first entry is loaded. With --all, all
+  MDR, MDV, and JSON cartridges in the archive are loaded into consecutive free
+  drives, in order of their names, starting at the drive given with --drive.
+  Free drives are those that are empty, or hold an unformatted and unmodified
+  cartridge. Hardware drives and drives mapped to a directory are skipped.
+
 - Repair currently only recalculates checksums and reverts sector order, if needed.
   If the cartridge is really broken, it won't be fixed this way.
 
@@ -61,10 +81,16 @@ func NewLoad() *Load {
 	l.AddSetting(&l.Repair, "repair", "r", "", false,
 		"try to repair cartridge if corrupted", false)
 	l.AddSetting(&l.Name, "name", "n", "", "",
-		"name to give to cartridge when loading a Z80 snapshot", false)
+		"name to give to cartridge when loading a Z80 snapshot or tape file", false)
 	l.AddSetting(&l.Launcher, "launcher", "l", "", "hidden",
 		`launcher type to use when loading a Z80 snapshot (hidden,
 screen)`, false)
+	l.AddSetting(&l.Loader, "loader", "", "", false,
+		"create run loader when loading a tape file", false)
+	l.AddSetting(&l.Entry, "entry", "e", "", "",
+		"entry to load from a zip, 7z, or tar archive", false)
+	l.AddSetting(&l.All, "all", "", "", false,
+		"load all cartridges in a zip, 7z, or tar archive into free drives", false)
 
 	return l
 }
@@ -78,8 +104,11 @@ type Load struct {
 	File     string
 	Name     string
 	Launcher string
+	Entry    string
 	Force    bool
 	Repair   bool
+	Loader   bool
+	All      bool
 }
 
 //
@@ -91,16 +120,32 @@ func (l *Load) Run() error {
 		return err
 	}
 
+	if l.All && l.Entry != "" {
+		return fmt.Errorf("--entry and --all cannot be used together")
+	}
+
 	name, typ, comp := format.SplitNameTypeCompressor(l.File)
 
+	if l.Entry != "" || l.All {
+		typ = "" // taken from archive entry
+	}
+
 	if l.Name != "" {
 		name = l.Name
 	}
 	name = strings.ToUpper(name)
 
 	path := fmt.Sprintf(
-		"/drive/%d?type=%s&compressor=%s&force=%v&repair=%v&name=%s&launcher=%s",
-		l.Drive, typ, comp, l.Force, l.Repair, url.QueryEscape(name), l.Launcher)
+		"/drive/%d?type=%s&compressor=%s&force=%v&repair=%v&name=%s&launcher=%s&loader=%v",
+		l.Drive, typ, comp, l.Force, l.Repair, url.QueryEscape(name), l.Launcher,
+		l.Loader)
+
+	if l.Entry != "" {
+		path += fmt.Sprintf("&entry=%s", url.QueryEscape(l.Entry))
+	}
+	if l.All {
+		path += "&all=true"
+	}
 
 	var in io.Reader
 	isRepo, _, err := repo.ParseReference(l.File)
@@ -0,0 +1,118 @@
+/*
+   OqtaDrive - Sinclair Microdrive emulator
+   Copyright (c) 2022, Alexander Vollschwitz
+
+   This file is part of OqtaDrive.
+
+   OqtaDrive is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   OqtaDrive is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with OqtaDrive. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package run
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/url"
+)
+
+//
+func NewMount() *Mount {
+
+	m := &Mount{}
+
+	m.Runner = *NewRunner(
+		`mount -d|--drive {drive} -p|--path {path} [-c|--client {if1|ql}] [-f|--force] [-a|--address {address}]
+  mount -d|--drive {drive} -u|--unmount [-a|--address {address}]`,
+		"map a directory in the cartridge repository to a drive",
+		`
+Use the mount command to map a directory inside the cartridge repository of the
+daemon to a drive. The daemon creates a cartridge from the files in that
+directory, and keeps cartridge and directory in sync: when files in the directory
+change, the cartridge is reloaded, and when the cartridge is changed by the
+Spectrum/QL or via the API, the changes are written back to the directory. The
+path is relative to the repository, so the daemon needs to have been started
+with a repository.
+
+Host files that start with a file header, as written by the get command when
+using the header wrapper, are put onto the cartridge with that header. Any other
+file is put on as plain data, i.e. as code on Spectrum, and as data file on QL.
+Files are written back with header, unless they were plain data and their header
+settings were not changed. If no client type is given, the cartridge is created
+for the currently connected adapter.
+
+The mapping ends when a different cartridge is placed into the drive, or when
+unmounting. Unmounting keeps the cartridge in the drive.`,
+		"", runnerHelpEpilogue, m.Run)
+
+	m.AddBaseSettings()
+	m.AddSetting(&m.Drive, "drive", "d", "", nil, "drive number (1-8)", true)
+	m.AddSetting(&m.Path, "path", "p", "", "",
+		"directory path relative to cartridge repository", false)
+	m.AddSetting(&m.Client, "client", "c", "", "",
+		"client type of cartridge, if1 or ql", false)
+	m.AddSetting(&m.Force, "force", "f", "", false,
+		"force replacing modified cartridge", false)
+	m.AddSetting(&m.Unmount, "unmount", "u", "", false,
+		"end directory mapping", false)
+
+	return m
+}
+
+//
+type Mount struct {
+	//
+	Runner
+	//
+	Drive   int
+	Path    string
+	Client  string
+	Force   bool
+	Unmount bool
+}
+
+//
+func (m *Mount) Run() error {
+
+	m.ParseSettings()
+
+	if err := validateDrive(m.Drive); err != nil {
+		return err
+	}
+
+	method := "DELETE"
+	path := fmt.Sprintf("/drive/%d/dir", m.Drive)
+
+	if !m.Unmount {
+		if m.Path == "" {
+			return fmt.Errorf("no directory path specified")
+		}
+		method = "PUT"
+		path = fmt.Sprintf("%s?path=%s&client=%s&force=%v", path,
+			url.QueryEscape(m.Path), url.QueryEscape(m.Client), m.Force)
+	}
+
+	resp, err := m.apiCall(method, path, false, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Close()
+
+	msg, err := ioutil.ReadAll(resp)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%s", msg)
+	return nil
+}
@@ -0,0 +1,197 @@
+/*
+   OqtaDrive - Sinclair Microdrive emulator
+   Copyright (c) 2022, Alexander Vollschwitz
+
+   This file is part of OqtaDrive.
+
+   OqtaDrive is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   OqtaDrive is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with OqtaDrive. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package run
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/xelalexv/oqtadrive/pkg/microdrive/if1"
+	"github.com/xelalexv/oqtadrive/pkg/util"
+)
+
+//
+func NewPut() *Put {
+
+	p := &Put{}
+	p.Runner = *NewRunner(
+		`put [-d|--drive {drive}] [-i|--input {cartridge file}]
+    {-s|--source {file} | -b|--basic {BASIC text file}} [-f|--file {name}] [-t|--type {file type}] [--start {address}]
+    [--line {auto-run line}] [--dataspace {size}] [-a|--address {address}]`,
+		"put file onto cartridge",
+		`
+Use the put command to place a file from the local file system onto a cartridge
+in the daemon, or into a cartridge file. A cartridge file is modified in place.`,
+		"", `- Start address and auto-run line are only used for Spectrum cartridges.
+  File types are 0 for BASIC, 1 for number array, 2 for character array, and
+  3 for code (default). If not specified, start address defaults to 23755 for
+  BASIC and to 32768 for all other types.
+
+- With --basic, a Spectrum BASIC program in text form is tokenized and stored
+  as a BASIC file. Keywords need to be in upper case, and each line needs to
+  start with a line number. Auto-run line defaults to the first line of the
+  program. Use a line number of 32768 or larger to disable auto-run.
+
+- For QL cartridges, file type is 0 for BASIC and data files (default), and
+  1 for executables. Data space is only used for executables.
+
+- Only uncompressed cartridge files in the native format of their client,
+  i.e. .mdr or .mdv, can be modified.
+
+`+runnerHelpEpilogue, p.Run)
+
+	p.AddBaseSettings()
+	p.AddSetting(&p.Drive, "drive", "d", "", 1, "drive number (1-8)", false)
+	p.AddSetting(&p.Input, "input", "i", "", nil, "cartridge file", false)
+	p.AddSetting(&p.Source, "source", "s", "", nil, "file to put", false)
+	p.AddSetting(&p.Basic, "basic", "b", "", nil,
+		"BASIC program in text form to put", false)
+	p.AddSetting(&p.File, "file", "f", "", nil,
+		"name of file on cartridge; defaults to source file name", false)
+	p.AddSetting(&p.Type, "type", "t", "", -1, "file type", false)
+	p.AddSetting(&p.Start, "start", "", "", -1, "start address", false)
+	p.AddSetting(&p.Line, "line", "", "", -1, "auto-run line for BASIC", false)
+	p.AddSetting(&p.Dataspace, "dataspace", "", "", -1,
+		"data space for QL executables", false)
+
+	return p
+}
+
+//
+type Put struct {
+	//
+	Runner
+	//
+	Drive     int
+	Input     string
+	Source    string
+	Basic     string
+	File      string
+	Type      int
+	Start     int
+	Line      int
+	Dataspace int
+}
+
+//
+func (p *Put) Run() error {
+
+	p.ParseSettings()
+
+	if (p.Source == "") == (p.Basic == "") {
+		return fmt.Errorf("specify either a source or a BASIC file")
+	}
+
+	params := util.Params{}
+	program := -1
+
+	var data []byte
+	var err error
+
+	if p.Basic != "" {
+		text, err := os.ReadFile(p.Basic)
+		if err != nil {
+			return err
+		}
+		var first int
+		if data, first, err = if1.Tokenize(string(text)); err != nil {
+			return fmt.Errorf("%s: %v", p.Basic, err)
+		}
+		p.Source = p.Basic
+		p.Type = 0
+		program = len(data)
+		params["programLength"] = program
+		if p.Line < 0 {
+			p.Line = first
+		}
+
+	} else if data, err = os.ReadFile(p.Source); err != nil {
+		return err
+	}
+
+	if p.File == "" {
+		p.File = filepath.Base(p.Source)
+		p.File = strings.TrimSuffix(p.File, filepath.Ext(p.File))
+	}
+
+	if p.Type > -1 {
+		params["fileType"] = p.Type
+	}
+	if p.Start > -1 {
+		params["startAddress"] = p.Start
+	}
+	if p.Line > -1 {
+		params["lineNumber"] = p.Line
+	}
+	if p.Dataspace > -1 {
+		params["dataspace"] = p.Dataspace
+	}
+
+	if p.Input != "" {
+		cart, err := readCartridge(p.Input, false)
+		if err != nil {
+			return err
+		}
+		if err := cart.FS().Create(p.File, data, params); err != nil {
+			return err
+		}
+		if err := writeCartridge(p.Input, cart); err != nil {
+			return err
+		}
+		fmt.Printf("put %s onto cartridge\n", p.File)
+		return nil
+	}
+
+	if err := validateDrive(p.Drive); err != nil {
+		return err
+	}
+
+	query := url.Values{}
+	for arg, val := range map[string]int{
+		"type": p.Type, "start": p.Start, "line": p.Line,
+		"dataspace": p.Dataspace, "program": program} {
+		if val > -1 {
+			query.Set(arg, strconv.Itoa(val))
+		}
+	}
+
+	resp, err := p.apiCall("PUT", fmt.Sprintf("/drive/%d/file/%s?%s",
+		p.Drive, url.PathEscape(p.File), query.Encode()), false,
+		bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	defer resp.Close()
+
+	msg, err := ioutil.ReadAll(resp)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%s", msg)
+	return nil
+}
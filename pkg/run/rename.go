@@ -0,0 +1,103 @@
+/*
+   OqtaDrive - Sinclair Microdrive emulator
+   Copyright (c) 2022, Alexander Vollschwitz
+
+   This file is part of OqtaDrive.
+
+   OqtaDrive is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   OqtaDrive is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with OqtaDrive. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package run
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/url"
+)
+
+//
+func NewRename() *Rename {
+
+	r := &Rename{}
+	r.Runner = *NewRunner(
+		`rename [-d|--drive {drive}] [-i|--input {cartridge file}] -f|--file {name}
+    -n|--name {new name} [-a|--address {address}]`,
+		"rename file on cartridge",
+		`
+Use the rename command to rename a file on a cartridge in the daemon, or in a
+cartridge file. A cartridge file is modified in place.`,
+		"", `- Only uncompressed cartridge files in the native format of their client,
+  i.e. .mdr or .mdv, can be modified.
+
+`+runnerHelpEpilogue, r.Run)
+
+	r.AddBaseSettings()
+	r.AddSetting(&r.Drive, "drive", "d", "", 1, "drive number (1-8)", false)
+	r.AddSetting(&r.Input, "input", "i", "", nil, "cartridge file", false)
+	r.AddSetting(&r.File, "file", "f", "", nil, "file to rename", true)
+	r.AddSetting(&r.Name, "name", "n", "", nil, "new file name", true)
+
+	return r
+}
+
+//
+type Rename struct {
+	//
+	Runner
+	//
+	Drive int
+	Input string
+	File  string
+	Name  string
+}
+
+//
+func (r *Rename) Run() error {
+
+	r.ParseSettings()
+
+	if r.Input != "" {
+		cart, err := readCartridge(r.Input, false)
+		if err != nil {
+			return err
+		}
+		if err := cart.FS().Rename(r.File, r.Name); err != nil {
+			return err
+		}
+		if err := writeCartridge(r.Input, cart); err != nil {
+			return err
+		}
+		fmt.Printf("renamed %s to %s\n", r.File, r.Name)
+		return nil
+	}
+
+	if err := validateDrive(r.Drive); err != nil {
+		return err
+	}
+
+	resp, err := r.apiCall("PUT", fmt.Sprintf("/drive/%d/file/%s/rename?to=%s",
+		r.Drive, url.PathEscape(r.File), url.QueryEscape(r.Name)), false, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Close()
+
+	msg, err := ioutil.ReadAll(resp)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%s", msg)
+	return nil
+}
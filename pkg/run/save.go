@@ -36,17 +36,27 @@ func NewSave() *Save {
 
 	s := &Save{}
 	s.Runner = *NewRunner(
-		"save [-d|--drive {drive}] -o|--output {file} [-f|--force] [-a|--address {address}]",
+		`save [-d|--drive {drive}] -o|--output {file} [-t|--type {format}] [-f|--force]
+     [-a|--address {address}]`,
 		"get cartridge from daemon and save",
 		"\nUse the save command to get a cartridge from the daemon and save it to a file.",
 		"", `- The format for saving the file is determined by the file extensions of the
-  given file name. Currently supported formats are .mdr and .mdv
+  given file name, unless set with --type. Currently supported formats are mdr,
+  mdv, and json. Spectrum cartridges can also be exported as tap or tzx, which
+  places all files of the cartridge into a tape file.
+
+- The json format is a lossless representation of the cartridge for use with
+  common JSON tools. It contains the bytes of each sector's header and record,
+  plus their decoded fields. When loading, changed fields take precedence over
+  the bytes. Use --repair when loading to fix check sums after editing.
 
 `+runnerHelpEpilogue, s.Run)
 
 	s.AddBaseSettings()
 	s.AddSetting(&s.File, "output", "o", "", nil, "cartridge output file", true)
 	s.AddSetting(&s.Drive, "drive", "d", "", 1, "drive number (1-8)", false)
+	s.AddSetting(&s.Type, "type", "t", "", nil,
+		"output format; defaults to file extension", false)
 	s.AddSetting(&s.Force, "force", "f", "", false,
 		"force overwriting output file", false)
 
@@ -60,6 +70,7 @@ type Save struct {
 	//
 	File  string
 	Drive int
+	Type  string
 	Force bool
 }
 
@@ -83,6 +94,9 @@ func (s *Save) Run() error {
 	if comp != "" {
 		return fmt.Errorf("compressed cartridge saving not supported")
 	}
+	if s.Type != "" {
+		typ = s.Type
+	}
 	resp, err := s.apiCall("GET",
 		fmt.Sprintf("/drive/%d?type=%s", s.Drive, typ), false, nil)
 	if err != nil {
@@ -40,7 +40,7 @@ func NewServe() *Serve {
 	s := &Serve{}
 	s.Runner = *NewRunner(
 		`serve -d|--device {device} [-b|--baud-rate {bps}] [-a|--address {address}]
-       [-c|--client {if1|ql}] [-r|--repo {repo base folder}]`,
+       [-c|--client {if1|ql}] [-r|--repo {repo base folder}] [--webdav]`,
 		"daemon & API server command",
 		`Use the serve command for running the adapter daemon and API server. Optionally, you
 can specify  whether the adapter  should be configured for  Interface 1 or QL  after
@@ -52,6 +52,11 @@ in its configuration, then this cannot be changed.`,
   Note that when setting the baud rate, the adapter needs to be programmed to the
   same speed.
 
+- With --webdav, the cartridges in drives 1 through 8 are shared via WebDAV at
+  path /dav/ of the API server. Each drive appears as a directory. Files can be
+  read, written, deleted, and renamed. Hardware drives, busy drives, and write
+  protected cartridges are refused with an error.
+
 - Logging can be configured with these environment variables:
 
   LOG_FORMAT		set to 'json' for JSON logging
@@ -71,6 +76,8 @@ in its configuration, then this cannot be changed.`,
 	s.AddSetting(&s.Repository, "repo", "r", "", nil,
 		`cartridge repo base folder; when omitted, loading
 cartridges from daemon host's file system is prohibited`, false)
+	s.AddSetting(&s.WebDAV, "webdav", "", "OQTADRIVE_WEBDAV", false,
+		"share drives via WebDAV", false)
 
 	return s
 }
@@ -84,6 +91,7 @@ type Serve struct {
 	BaudRate   uint
 	Client     string
 	Repository string
+	WebDAV     bool
 }
 
 //
@@ -121,7 +129,7 @@ func (s *Serve) Run() error {
 		}
 	}()
 
-	api := control.NewAPIServer(s.Address, s.Repository, d)
+	api := control.NewAPIServer(s.Address, s.Repository, s.WebDAV, d)
 	go func() {
 		defer wg.Done()
 		if err := api.Serve(); err != nil {
@@ -0,0 +1,112 @@
+/*
+   OqtaDrive - Sinclair Microdrive emulator
+   Copyright (c) 2022, Alexander Vollschwitz
+
+   This file is part of OqtaDrive.
+
+   OqtaDrive is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   OqtaDrive is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with OqtaDrive. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package screen
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+)
+
+// QL screen geometry
+const (
+	QLSize         = 32768
+	QLBytesPerLine = 128
+	QLHeight       = 256
+	QLWidthMode4   = 512
+	QLWidthMode8   = 256
+)
+
+// QL colour palette, index composed of green, red, blue bits
+var qlPalette = color.Palette{
+	color.RGBA{0x00, 0x00, 0x00, 0xff}, // black
+	color.RGBA{0x00, 0x00, 0xff, 0xff}, // blue
+	color.RGBA{0xff, 0x00, 0x00, 0xff}, // red
+	color.RGBA{0xff, 0x00, 0xff, 0xff}, // magenta
+	color.RGBA{0x00, 0xff, 0x00, 0xff}, // green
+	color.RGBA{0x00, 0xff, 0xff, 0xff}, // cyan
+	color.RGBA{0xff, 0xff, 0x00, 0xff}, // yellow
+	color.RGBA{0xff, 0xff, 0xff, 0xff}, // white
+}
+
+// RenderQL renders QL display memory into an image. Mode is either 4, for the
+// 512x256 four colour mode, or 8, for the 256x256 eight colour mode. Flashing
+// is not rendered.
+func RenderQL(data []byte, mode int) (image.Image, error) {
+
+	if len(data) < QLSize {
+		return nil, fmt.Errorf("screen data too short: %d bytes", len(data))
+	}
+
+	var img *image.Paletted
+
+	switch mode {
+
+	case 4:
+		img = image.NewPaletted(
+			image.Rect(0, 0, QLWidthMode4, QLHeight), qlPalette)
+		for y := 0; y < QLHeight; y++ {
+			for w := 0; w < QLBytesPerLine/2; w++ {
+				// even byte holds green bits, odd byte red bits, 8 pixels
+				g := data[y*QLBytesPerLine+2*w]
+				r := data[y*QLBytesPerLine+2*w+1]
+				for p := 0; p < 8; p++ {
+					c := (g>>(7-p)&1)<<2 | (r>>(7-p)&1)<<1
+					if c == 6 { // green plus red is white in mode 4
+						c = 7
+					}
+					img.SetColorIndex(8*w+p, y, c)
+				}
+			}
+		}
+
+	case 8:
+		img = image.NewPaletted(
+			image.Rect(0, 0, QLWidthMode8, QLHeight), qlPalette)
+		for y := 0; y < QLHeight; y++ {
+			for w := 0; w < QLBytesPerLine/2; w++ {
+				// even byte holds green & flash bits, odd byte red & blue
+				// bits, 4 pixels
+				gf := data[y*QLBytesPerLine+2*w]
+				rb := data[y*QLBytesPerLine+2*w+1]
+				for p := 0; p < 4; p++ {
+					s := 7 - 2*p
+					c := (gf>>s&1)<<2 | (rb>>s&1)<<1 | rb>>(s-1)&1
+					img.SetColorIndex(4*w+p, y, c)
+				}
+			}
+		}
+
+	default:
+		return nil, fmt.Errorf("invalid QL screen mode: %d", mode)
+	}
+
+	return img, nil
+}
+
+// QLPNG renders QL display memory as PNG
+func QLPNG(data []byte, mode int) ([]byte, error) {
+	img, err := RenderQL(data, mode)
+	if err != nil {
+		return nil, err
+	}
+	return encodePNG(img)
+}
@@ -0,0 +1,116 @@
+/*
+   OqtaDrive - Sinclair Microdrive emulator
+   Copyright (c) 2022, Alexander Vollschwitz
+
+   This file is part of OqtaDrive.
+
+   OqtaDrive is free software: you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as published by
+   the Free Software Foundation, either version 3 of the License, or
+   (at your option) any later version.
+
+   OqtaDrive is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+   GNU General Public License for more details.
+
+   You should have received a copy of the GNU General Public License
+   along with OqtaDrive. If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package screen
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+)
+
+// Spectrum screen geometry
+const (
+	SpectrumWidth      = 256
+	SpectrumHeight     = 192
+	SpectrumBitmapSize = 6144
+	SpectrumSize       = 6912 // bitmap plus attributes
+	SpectrumAddress    = 16384
+)
+
+// Spectrum colour palette, normal and bright
+var spectrumPalette = color.Palette{
+	color.RGBA{0x00, 0x00, 0x00, 0xff},
+	color.RGBA{0x00, 0x00, 0xd7, 0xff},
+	color.RGBA{0xd7, 0x00, 0x00, 0xff},
+	color.RGBA{0xd7, 0x00, 0xd7, 0xff},
+	color.RGBA{0x00, 0xd7, 0x00, 0xff},
+	color.RGBA{0x00, 0xd7, 0xd7, 0xff},
+	color.RGBA{0xd7, 0xd7, 0x00, 0xff},
+	color.RGBA{0xd7, 0xd7, 0xd7, 0xff},
+	color.RGBA{0x00, 0x00, 0x00, 0xff},
+	color.RGBA{0x00, 0x00, 0xff, 0xff},
+	color.RGBA{0xff, 0x00, 0x00, 0xff},
+	color.RGBA{0xff, 0x00, 0xff, 0xff},
+	color.RGBA{0x00, 0xff, 0x00, 0xff},
+	color.RGBA{0x00, 0xff, 0xff, 0xff},
+	color.RGBA{0xff, 0xff, 0x00, 0xff},
+	color.RGBA{0xff, 0xff, 0xff, 0xff},
+}
+
+// RenderSpectrum renders Spectrum screen memory, i.e. 6144 bytes of bitmap
+// followed by 768 bytes of attributes, into an image. When flash is set,
+// the image shows the flash phase in which ink and paper of flashing character
+// cells are swapped.
+func RenderSpectrum(data []byte, flash bool) (image.Image, error) {
+
+	if len(data) < SpectrumSize {
+		return nil, fmt.Errorf("screen data too short: %d bytes", len(data))
+	}
+
+	img := image.NewPaletted(
+		image.Rect(0, 0, SpectrumWidth, SpectrumHeight), spectrumPalette)
+
+	for y := 0; y < SpectrumHeight; y++ {
+		// bitmap lines are interleaved in thirds of the screen
+		line := (y&0xc0)<<5 | (y&0x07)<<8 | (y&0x38)<<2
+		for col := 0; col < SpectrumWidth/8; col++ {
+
+			attr := data[SpectrumBitmapSize+(y/8)*32+col]
+			bright := (attr & 0x40) >> 3
+			ink := attr&0x07 | bright
+			paper := (attr>>3)&0x07 | bright
+			if flash && attr&0x80 != 0 {
+				ink, paper = paper, ink
+			}
+
+			bits := data[line+col]
+			for b := 0; b < 8; b++ {
+				if bits&(0x80>>b) != 0 {
+					img.SetColorIndex(col*8+b, y, ink)
+				} else {
+					img.SetColorIndex(col*8+b, y, paper)
+				}
+			}
+		}
+	}
+
+	return img, nil
+}
+
+// SpectrumPNG renders Spectrum screen memory as PNG
+func SpectrumPNG(data []byte, flash bool) ([]byte, error) {
+	img, err := RenderSpectrum(data, flash)
+	if err != nil {
+		return nil, err
+	}
+	return encodePNG(img)
+}
+
+//
+func encodePNG(img image.Image) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
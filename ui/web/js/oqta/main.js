@@ -69,6 +69,9 @@ function getFormatCompressor(file) {
     var compressor = getCompressor(file);
     if (compressor != '') {
         file = removeExtension(file);
+        if (compressor.startsWith('tar.')) {
+            file = removeExtension(file);
+        }
     }
     var format = getFormat(file);
     return {
@@ -85,6 +88,8 @@ function getFormat(file) {
         case 'mdr':
         case 'z80':
         case 'sna':
+        case 'tap':
+        case 'tzx':
             return ext;
     }
     return '';
@@ -96,8 +101,20 @@ function getCompressor(file) {
     switch (ext) {
         case 'gz':
         case 'gzip':
+        case 'bz2':
+        case 'bzip2':
+        case 'xz':
+            if (getExtension(removeExtension(file)) == 'tar') {
+                return 'tar.' + ext;
+            }
+            return ext;
         case 'zip':
         case '7z':
+        case 'tar':
+        case 'tgz':
+        case 'tbz':
+        case 'tbz2':
+        case 'txz':
             return ext;
     }
     return '';
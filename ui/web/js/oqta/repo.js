@@ -88,13 +88,29 @@ function updateSearchResults(data) {
         li = document.createElement('li');
         li.className = "list-group-item text-white bg-dark";
         li.onclick = function() {
-            searchItemSelected(this.innerHTML);
+            searchItemSelected(l);
+        }
+        if (hasThumbnail(l)) {
+            var img = document.createElement('img');
+            img.src = '/repo/thumbnail?path=' + encodeURIComponent(l);
+            img.height = 48;
+            img.className = "me-2";
+            img.onerror = function() {
+                this.remove();
+            }
+            li.appendChild(img);
         }
         li.appendChild(document.createTextNode(l));
         list.appendChild(li);
     });
 }
 
+//
+function hasThumbnail(item) {
+    var fc = getFormatCompressor(item);
+    return fc.format == 'z80' || fc.format == 'sna';
+}
+
 //
 function searchItemSelected(item) {
     userConfirm("Load cartridge?",